@@ -3,83 +3,177 @@ package cli
 import (
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 
-	bboxclient "bbox-cli/client"
-
 	"github.com/joho/godotenv"
 )
 
 func Run() {
 	godotenv.Load()
-	if len(os.Args) < 2 {
-		PrintUsage()
-		os.Exit(1)
+	args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
 	}
-
-	// Get password from env
-	password := os.Getenv("BBOX_PWD")
-	if password == "" {
-		fmt.Println("Error: BBOX_PWD env variable not set")
-		os.Exit(1)
+	if len(args) < 1 {
+		PrintUsage()
+		exit(1)
 	}
 
-	// Parse URL
-	baseURL := "https://mabbox.bytel.fr/api/v1"
-	parsedURL, err := url.Parse(baseURL)
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Invalid URL: %v", err)
+		fatalf("Error loading configuration: %v", err)
 	}
-
-	// Create client
-	client, err := bboxclient.NewClient(parsedURL)
+	profile, err := cfg.resolveProfile(globals.profile, globals.url)
 	if err != nil {
-		log.Fatalf("Error creating client: %v", err)
-	}
-
-	// Authenticate
-	authInterface := client.Auth()
-	if err := authInterface.BasicAuth(password); err != nil {
-		log.Fatalf("Authentication failed: %v", err)
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
 	}
 
-	// Start token refresher
-	authInterface.StartTokenRefresher()
+	conn := &connection{profile: profile}
+	active = conn
+	defer conn.Close()
 
 	// Parse subcommand
-	subcommand := os.Args[1]
+	subcommand := args[0]
 
 	switch subcommand {
 	case "nat":
-		handleNat(client, os.Args[2:])
+		handleNat(conn, args[1:])
 	case "firewall":
-		handleFirewall(client, os.Args[2:])
+		handleFirewall(conn, args[1:])
+	case "firewall6":
+		handleFirewall6(conn, args[1:])
+	case "export":
+		handleExport(conn, args[1:])
+	case "restore":
+		handleRestore(conn, args[1:])
+	case "diff":
+		handleDiff(conn, args[1:])
+	case "login":
+		handleLogin(conn, args[1:])
+	case "logout":
+		handleLogout(conn, args[1:])
+	case "history":
+		handleHistory(conn, args[1:])
+	case "undo":
+		handleUndo(conn, args[1:])
 	case "help":
 		PrintUsage()
 	default:
 		fmt.Printf("Unknown command: %s\n", subcommand)
 		PrintUsage()
-		os.Exit(1)
+		exit(1)
 	}
 }
 
+// active is the connection of the running command, whose session is saved
+// by exit
+var active *connection
+
+// exit saves the session of the active connection, which may have been
+// renewed during the command, and ends the program. Deferred calls do not
+// run on os.Exit, so commands exit through here.
+func exit(code int) {
+	if active != nil {
+		active.Close()
+	}
+	os.Exit(code)
+}
+
+// fatalf logs the message like log.Fatalf but exits through exit
+func fatalf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	exit(1)
+}
+
 func PrintUsage() {
 	fmt.Println("bboxcli - Bbox Configuration Tool")
 	fmt.Println()
-	fmt.Println("Usage: bboxcli <command> [options]")
+	fmt.Println("Usage: bboxcli [global flags] <command> [options]")
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  firewall show        Show all firewall rules")
 	fmt.Println("  firewall show <id>   Show detailed firewall rule")
-	fmt.Println("  firewall add <rule>  Add a new firewall rule")
+	fmt.Println("  firewall add [flags] Add a new firewall rule")
+	fmt.Println("  firewall edit <id> [flags] Edit a firewall rule")
 	fmt.Println("  firewall delete <id> Delete a firewall rule")
+	fmt.Println("  firewall lint [--ignore <checks>] [--disabled-for <duration>]  Report shadowed, duplicate,")
+	fmt.Println("                       conflicting, wide-accept and empty rules, and rules disabled for longer than")
+	fmt.Println("                       --disabled-for (30 days by default, ages from the journal); exits with")
+	fmt.Println("                       status 1 unless only disabled rules are found")
+	fmt.Println("  firewall test [--src <ip>] [--dst <ip>] [--sport <port>] [--dport <port>]")
+	fmt.Println("        [--proto <tcp|udp>] [--ip <v4|v6>]  Show which rule would match a packet")
+	fmt.Println("  firewall move <id> --before <id>|--after <id>|--to <n> [--dry-run]")
+	fmt.Println("                       Change the precedence of a rule and renumber the others")
+	fmt.Println("  firewall reorder [id...] [--dry-run]  Renumber rules from 1, listed IDs first")
+	fmt.Println("  firewall status      Show the firewall level, ping responder and gamer mode")
+	fmt.Println("  firewall level <low|medium|high|custom>  Set the firewall security level")
+	fmt.Println("  firewall ping-responder <on|off>        Answer pings from the internet or not")
+	fmt.Println("  firewall gamer-mode <on|off>            Turn the gamer mode on or off")
+	fmt.Println("  firewall plan -f <file> [--prune]   Show changes needed to match a rule file")
+	fmt.Println("  firewall apply -f <file> [--prune]  Apply a rule file to the router")
+	fmt.Println("  firewall6 show       Show all IPv6 pinholes")
+	fmt.Println("  firewall6 show <id>  Show detailed IPv6 pinhole")
+	fmt.Println("  firewall6 add [flags] Add an IPv6 pinhole (interactive without flags)")
+	fmt.Println("  firewall6 edit <id> [flags] Edit an IPv6 pinhole (interactive without flags)")
+	fmt.Println("  firewall6 delete <id> Delete an IPv6 pinhole")
 	fmt.Println("  nat show             Show all NAT rules")
 	fmt.Println("  nat show <id>        Show detailed NAT rule")
 	fmt.Println("  nat enable <id>      Enable a NAT rule")
 	fmt.Println("  nat disable <id>     Disable a NAT rule")
+	fmt.Println("  nat add [flags]      Add a NAT rule (interactive without flags)")
+	fmt.Println("  nat edit <id> [flags] Edit a NAT rule (interactive without flags)")
+	fmt.Println("  nat delete <id>      Delete a NAT rule")
+	fmt.Println("  export [--what firewall,nat] [-o <file>]  Save rules and router details to a snapshot")
+	fmt.Println("  restore <file> [--dry-run] [--yes]  Re-create the rules of a snapshot, reusing matching ones;")
+	fmt.Println("                       asks for confirmation unless --yes is given")
+	fmt.Println("  diff <a> <b> | diff <a> --live [--no-color]  Compare two snapshots, or one with the")
+	fmt.Println("                       router; exits with status 1 when they differ")
+	fmt.Println("  login                Check the password and store it in the keyring of the system")
+	fmt.Println("  logout [--forget]    End the session and clear its cache; --forget also removes")
+	fmt.Println("                       the stored password")
+	fmt.Println("  history [--all] [-n <count>]  Show the changes made to firewall and NAT rules while")
+	fmt.Println("                       journaling (see --journal)")
+	fmt.Println("  undo [n] [--force] [--dry-run]  Revert the last n changes of the profile (default 1)")
 	fmt.Println("  help                 Show this help message")
 	fmt.Println()
+	fmt.Println("Firewall rule flags (missing values are prompted for in a terminal):")
+	fmt.Println("  --description <text> --action <Accept|Drop> --src <ip> --src-ports <ports>")
+	fmt.Println("  --dst <ip> --dst-ports <ports> --proto <tcp|udp|tcp,udp> --ip-version <v4|v6|both>")
+	fmt.Println("  --order <n> --enable | --disable    (prefix addresses or ports with ! to negate)")
+	fmt.Println()
+	fmt.Println("IPv6 pinhole flags:")
+	fmt.Println("  --description <text> --dst <ipv6|prefix> --dst-ports <ports> --src <ipv6|prefix>")
+	fmt.Println("  --src-ports <ports> --proto <tcp|udp|tcp,udp> --enable | --disable")
+	fmt.Println()
+	fmt.Println("NAT rule flags:")
+	fmt.Println("  --description <text> --proto <tcp|udp|tcp,udp> --external-ip <ip>")
+	fmt.Println("  --external-port <port> --internal-ip <ip> --internal-port <port>")
+	fmt.Println("  --enable | --disable")
+	fmt.Println()
+	fmt.Println("Global flags:")
+	fmt.Println("  --output <format>    Output format for show commands: table (default), wide,")
+	fmt.Println("                       json, yaml, csv or tsv")
+	fmt.Println("  --profile <name>     Configuration profile to use")
+	fmt.Println("  --url <url>          API root of the router, e.g. https://192.168.1.254/api/v1")
+	fmt.Println("  --timeout <duration> Time limit of each request, e.g. 10s (default 30s, 0 for none)")
+	fmt.Println("  --retries <n>        Retries of reads and updates failing while the router is busy")
+	fmt.Println("                       (default 2, 0 to disable); creations are never retried")
+	fmt.Println("  --password-file <file>  Read the router password from the first line of a file")
+	fmt.Println("  --password-stdin     Read the router password from the first line of stdin")
+	fmt.Println("  --journal            Record rule changes for history and undo (or journal: true in")
+	fmt.Println("                       the profile); each change then reads the rules twice more")
+	fmt.Println()
+	fmt.Println("The password is taken from --password-stdin, --password-file, the BBOX_PWD variable")
+	fmt.Println("(or password_env of the profile), password_command of the profile, then the keyring.")
+	fmt.Println()
 	fmt.Println("Environment variables:")
-	fmt.Println("  BBOX_PWD            Password for Bbox authentication (required, can be set in .env file)")
+	fmt.Println("  BBOX_PWD            Password for Bbox authentication (can be set in .env file)")
+	fmt.Println("  BBOX_URL            API root of the router (default " + defaultBaseURL + ")")
+	fmt.Println("  BBOX_PROFILE        Configuration profile to use")
+	fmt.Println("  BBOXCLI_CONFIG      Configuration file (default ~/.config/bboxcli/config.yaml)")
+	fmt.Println("  BBOXCLI_KEYRING     Password store: secret-service (needs secret-tool) or file")
+	fmt.Println("  BBOXCLI_KEYRING_PASSPHRASE  Passphrase of the encrypted keyring file")
+	fmt.Println("  BBOXCLI_JOURNAL     Change journal (default ~/.local/state/bboxcli/journal.jsonl)")
 }
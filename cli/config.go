@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultBaseURL is used when neither the command line, the environment nor
+// the profile specify a router
+const defaultBaseURL = "https://mabbox.bytel.fr/api/v1"
+
+// defaultProfileName is the profile used when none is selected
+const defaultProfileName = "default"
+
+// Config is the content of the bboxcli configuration file, e.g.
+//
+//	default_profile: home
+//	profiles:
+//	  home:
+//	    url: https://192.168.1.254/api/v1
+//	    tls:
+//	      insecure_skip_verify: true
+//	  office:
+//	    url: https://office.example.com:8443/api/v1
+//	    password_env: BBOX_OFFICE_PWD
+//	    journal: true
+//	  travel:
+//	    url: https://192.168.1.254/api/v1
+//	    password_command: pass show bbox/travel
+type Config struct {
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// Profile describes how to reach and authenticate against one router
+type Profile struct {
+	Name string `yaml:"-"`
+
+	// URL is the API root of the router
+	URL string `yaml:"url"`
+
+	// PasswordEnv names the environment variable holding the password
+	PasswordEnv string `yaml:"password_env"`
+
+	// PasswordCommand is a shell command printing the password, e.g.
+	// "pass show bbox"
+	PasswordCommand string `yaml:"password_command"`
+
+	// Journal records rule changes for history and undo. Each change then
+	// reads the rule list twice more.
+	Journal bool `yaml:"journal"`
+
+	TLS TLSOptions `yaml:"tls"`
+}
+
+// TLSOptions tunes certificate verification, which is mostly needed for
+// routers reached by IP address with their self-signed certificate
+type TLSOptions struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	CAFile             string `yaml:"ca_file"`
+	ServerName         string `yaml:"server_name"`
+}
+
+// configPath returns the location of the configuration file, honouring
+// BBOXCLI_CONFIG
+func configPath() (string, error) {
+	if path := os.Getenv("BBOXCLI_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bboxcli", "config.yaml"), nil
+}
+
+// loadConfig reads the configuration file. A missing file is not an error
+// and yields an empty configuration.
+func loadConfig() (*Config, error) {
+	cfg := &Config{}
+
+	path, err := configPath()
+	if err != nil {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// resolveProfile selects the profile to use and applies the overrides from
+// the command line and the environment. The profile is chosen from
+// --profile, then BBOX_PROFILE, then the default_profile setting; the URL
+// from --url, then BBOX_URL, then the profile.
+func (c *Config) resolveProfile(name, urlOverride string) (Profile, error) {
+	explicit := true
+	if name == "" {
+		name = os.Getenv("BBOX_PROFILE")
+	}
+	if name == "" {
+		explicit = false
+		name = defaultProfileName
+		if c.DefaultProfile != "" {
+			name = c.DefaultProfile
+			explicit = true
+		}
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok && explicit {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	profile.Name = name
+
+	if urlOverride == "" {
+		urlOverride = os.Getenv("BBOX_URL")
+	}
+	if urlOverride != "" {
+		profile.URL = urlOverride
+	}
+	if profile.URL == "" {
+		profile.URL = defaultBaseURL
+	}
+
+	if profile.PasswordEnv == "" {
+		profile.PasswordEnv = "BBOX_PWD"
+	}
+	return profile, nil
+}
+
+// tlsConfig builds the TLS settings of the profile, or nil when the
+// defaults apply
+func (p Profile) tlsConfig() (*tls.Config, error) {
+	opts := p.TLS
+	if !opts.InsecureSkipVerify && opts.CAFile == "" && opts.ServerName == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		ServerName:         opts.ServerName,
+	}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
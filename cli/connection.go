@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	bboxclient "bbox-cli/client"
+)
+
+// connection logs into the router on first use, so that commands can reject
+// invalid input before any request is made
+type connection struct {
+	profile Profile
+	client  *bboxclient.BboxClient
+}
+
+// Client returns the authenticated client, logging in or resuming the cached
+// session the first time it is called
+func (c *connection) Client() *bboxclient.BboxClient {
+	if c.client != nil {
+		return c.client
+	}
+
+	password, err := resolvePassword(c.profile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+	if err := c.open(password, false); err != nil {
+		fatalf("Authentication failed: %v", err)
+	}
+	return c.client
+}
+
+// open creates the client and authenticates with password. Unless fresh is
+// set, the cached session is resumed when still usable.
+func (c *connection) open(password string, fresh bool) error {
+	client := c.newClient()
+
+	// Authenticate, reusing the cached session when possible
+	var err error
+	if fresh {
+		err = client.Auth().BasicAuth(password)
+	} else {
+		err = authenticate(client, c.profile, password)
+	}
+	if err != nil {
+		return err
+	}
+	if err := saveSession(c.profile, client.Session()); err != nil {
+		log.Printf("Warning: could not cache session: %v", err)
+	}
+
+	c.client = client
+	return nil
+}
+
+// newClient creates an unauthenticated client for the profile
+func (c *connection) newClient() *bboxclient.BboxClient {
+	// Parse URL
+	parsedURL, err := url.Parse(c.profile.URL)
+	if err != nil {
+		fatalf("Invalid URL: %v", err)
+	}
+
+	tlsConfig, err := c.profile.tlsConfig()
+	if err != nil {
+		fatalf("Error loading TLS settings: %v", err)
+	}
+
+	// Create client
+	opts := []bboxclient.Option{
+		bboxclient.WithTimeout(globals.timeout),
+		bboxclient.WithMaxAttempts(globals.retries + 1),
+	}
+	if globals.journal || c.profile.Journal {
+		opts = append(opts, bboxclient.WithJournal(newFileJournal(c.profile)))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, bboxclient.WithTLSConfig(tlsConfig))
+	}
+	client, err := bboxclient.NewClient(parsedURL, opts...)
+	if err != nil {
+		fatalf("Error creating client: %v", err)
+	}
+	return client
+}
+
+// Close saves the session, which may have been renewed while running the
+// command
+func (c *connection) Close() {
+	if c.client != nil {
+		saveSession(c.profile, c.client.Session())
+	}
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	bboxclient "bbox-cli/client"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+	colorReset  = "\x1b[0m"
+)
+
+// handleDiff implements "bboxcli diff <a> <b>" and "bboxcli diff <a> --live".
+// Like diff(1) it exits with status 1 when the snapshots differ.
+func handleDiff(conn *connection, args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	live := flags.Bool("live", false, "Compare the snapshot with the rules on the router")
+	noColor := flags.Bool("no-color", false, "Do not colour the output")
+	files := parseInterspersed(flags, args)
+
+	if (*live && len(files) != 1) || (!*live && len(files) != 2) {
+		fmt.Println("Error: diff needs two snapshot files, or one file and --live")
+		exit(1)
+	}
+
+	old, err := loadSnapshot(files[0])
+	if err != nil {
+		fatalf("Error reading %s: %v", files[0], err)
+	}
+	oldName, newName := files[0], "live router"
+
+	var updated bboxclient.Snapshot
+	if *live {
+		updated, err = conn.Client().TakeSnapshot(old.Meta.Sections...)
+		if err != nil {
+			fatalf("Error: %v", err)
+		}
+	} else {
+		newName = files[1]
+		if updated, err = loadSnapshot(files[1]); err != nil {
+			fatalf("Error reading %s: %v", files[1], err)
+		}
+	}
+
+	diffs := bboxclient.DiffSnapshots(old, updated)
+	if diffs == nil {
+		diffs = []bboxclient.RuleDiff{}
+	}
+
+	switch {
+	case globals.output.structured():
+		err = writeStructured(globals.output, diffs)
+	case globals.output.delimited():
+		err = writeDiffRows(diffs)
+	default:
+		color := !*noColor && os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd()))
+		printUnifiedDiff(old, updated, oldName, newName, diffs, color)
+	}
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	if len(diffs) > 0 {
+		exit(1)
+	}
+}
+
+func printUnifiedDiff(old, updated bboxclient.Snapshot, oldName, newName string, diffs []bboxclient.RuleDiff, color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	for _, s := range []string{bboxclient.SnapshotFirewall, bboxclient.SnapshotNat} {
+		if old.Has(s) != updated.Has(s) {
+			fmt.Printf("Note: %s rules are only in one of the snapshots and are not compared\n", s)
+		}
+	}
+	if len(diffs) == 0 {
+		fmt.Println("No differences")
+		return
+	}
+
+	fmt.Println(paint(colorRed, "--- "+oldName+describeSnapshot(old)))
+	fmt.Println(paint(colorGreen, "+++ "+newName+describeSnapshot(updated)))
+
+	section := ""
+	for _, d := range diffs {
+		if d.Section != section {
+			section = d.Section
+			fmt.Println(paint(colorCyan, fmt.Sprintf("@@ %s @@", section)))
+		}
+		switch d.Kind {
+		case bboxclient.DiffAdded:
+			fmt.Println(paint(colorGreen, fmt.Sprintf("+ rule %q (ID %d)", d.Name, d.NewID)))
+		case bboxclient.DiffRemoved:
+			fmt.Println(paint(colorRed, fmt.Sprintf("- rule %q (ID %d)", d.Name, d.OldID)))
+		case bboxclient.DiffModified:
+			ids := fmt.Sprintf("ID %d", d.OldID)
+			if d.NewID != d.OldID {
+				ids = fmt.Sprintf("ID %d -> %d", d.OldID, d.NewID)
+			}
+			fmt.Println(paint(colorYellow, fmt.Sprintf("~ rule %q (%s)", d.Name, ids)))
+			for _, c := range d.Changes {
+				fmt.Println(paint(colorRed, fmt.Sprintf("-     %s: %s", c.Field, c.Old)))
+				fmt.Println(paint(colorGreen, fmt.Sprintf("+     %s: %s", c.Field, c.New)))
+			}
+		}
+	}
+}
+
+// describeSnapshot is the router and date shown next to a file name
+func describeSnapshot(s bboxclient.Snapshot) string {
+	var parts []string
+	if s.Meta.Model != "" {
+		parts = append(parts, s.Meta.Model)
+	}
+	if s.Meta.Firmware != "" {
+		parts = append(parts, "firmware "+s.Meta.Firmware)
+	}
+	if !s.Meta.CreatedAt.IsZero() {
+		parts = append(parts, s.Meta.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "\t(" + strings.Join(parts, ", ") + ")"
+}
+
+// writeDiffRows prints one CSV/TSV row per changed field
+func writeDiffRows(diffs []bboxclient.RuleDiff) error {
+	headers := []string{"section", "kind", "name", "old_id", "new_id", "field", "old", "new"}
+	var rows [][]string
+	for _, d := range diffs {
+		row := []string{d.Section, string(d.Kind), d.Name, idOrEmpty(d.OldID), idOrEmpty(d.NewID)}
+		if len(d.Changes) == 0 {
+			rows = append(rows, append(row, "", "", ""))
+		}
+		for _, c := range d.Changes {
+			rows = append(rows, append(append([]string(nil), row...), c.Field, c.Old, c.New))
+		}
+	}
+	return writeDelimited(globals.output, headers, rows)
+}
+
+// idOrEmpty leaves the ID of the missing side of an added or removed rule blank
+func idOrEmpty(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
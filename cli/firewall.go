@@ -1,14 +1,15 @@
 package cli
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"strconv"
 
 	bboxclient "bbox-cli/client"
 )
 
-func handleFirewall(client *bboxclient.BboxClient, args []string) {
+func handleFirewall(conn *connection, args []string) {
 	if len(args) < 1 {
 		PrintUsage()
 		return
@@ -20,56 +21,60 @@ func handleFirewall(client *bboxclient.BboxClient, args []string) {
 	case "show":
 		if len(args) > 1 {
 			// Show detailed view for specific ID
-			showFirewallDetail(client, args[1])
+			showFirewallDetail(conn.Client(), args[1])
 		} else {
 			// Show list view
-			showFirewallList(client)
+			showFirewallList(conn.Client())
 		}
 	case "add":
-		rule := handleRuleCreation()
-		addFirewallRule(client, rule)
+		flags, opts := firewallRuleFlags("firewall add")
+		flags.Parse(args[1:])
+		rule, err := handleRuleCreation(flags, opts)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		rule.Normalize()
+		exitOnInvalidRule(rule)
+		addFirewallRule(conn.Client(), rule)
 	case "delete":
 		if len(args) < 2 {
 			PrintUsage()
 			return
 		}
-		deleteFirewallRule(client, args[1])
-	case "edit":
+		deleteFirewallRule(conn.Client(), args[1])
+	case "status":
+		showFirewallStatus(conn.Client())
+	case "level":
 		if len(args) < 2 {
 			PrintUsage()
 			return
 		}
-		ruleID, err := strconv.Atoi(args[1])
-		if err != nil {
-			fmt.Printf("Error: invalid ID '%s'\n", args[1])
-			return
-		}
-
-		fw := client.Firewall()
-		rules, err := fw.GetFirewallRules()
-		if err != nil {
-			log.Fatalf("Error: %v", err)
-		}
-
-		var existingRule *bboxclient.FirewallRule
-		for i := range rules {
-			if rules[i].ID == ruleID {
-				existingRule = &rules[i]
-				break
-			}
-		}
-
-		if existingRule == nil {
-			fmt.Printf("Error: Rule with ID %d not found\n", ruleID)
+		setFirewallLevel(conn.Client(), args[1])
+	case "ping-responder", "gamer-mode":
+		if len(args) < 2 {
+			PrintUsage()
 			return
 		}
-
-		err = updateFirewallRule(client, handleRuleEditing(*existingRule))
-		if err != nil {
-			fmt.Printf("Error updating firewall rule: %v\n", err)
+		setFirewallSwitch(conn.Client(), action, args[1])
+	case "lint":
+		handleFirewallLint(conn, args[1:])
+	case "test":
+		handleFirewallTest(conn, args[1:])
+	case "move":
+		handleFirewallMove(conn, args[1:])
+	case "reorder":
+		handleFirewallReorder(conn, args[1:])
+	case "plan":
+		handleFirewallApply(conn, args[1:], false)
+	case "apply":
+		handleFirewallApply(conn, args[1:], true)
+	case "edit":
+		if len(args) < 2 {
+			PrintUsage()
 			return
 		}
-		fmt.Println("Firewall rule updated successfully")
+		editFirewallRule(conn.Client(), args[1], args[2:])
 	default:
 		fmt.Printf("Unknown firewall action: %s\n", action)
 		PrintUsage()
@@ -80,7 +85,15 @@ func showFirewallList(client *bboxclient.BboxClient) {
 	fw := client.Firewall()
 	rules, err := fw.GetFirewallRules()
 	if err != nil {
-		log.Fatalf("Error: %v", err)
+		fatalf("Error: %v", err)
+	}
+	bboxclient.SortFirewallRules(rules)
+
+	if globals.output != outputTable {
+		if err := writeFirewallRules(rules); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
 	}
 
 	if len(rules) == 0 {
@@ -88,36 +101,26 @@ func showFirewallList(client *bboxclient.BboxClient) {
 		return
 	}
 
-	fmt.Printf("%-5s %-3s %-15s %-10s %-15s %-15s %-15s %-15s\n",
-		"", "ID", "DESCRIPTION", "ACTION", "DST IP", "DST PORTS", "SRC IP", "SRC PORTS")
-	fmt.Println(repeatString("-", 100))
-
+	columns := []tableColumn{
+		{"ID", 3, true}, {"ORDER", 5, true}, {"DESCRIPTION", 15, false}, {"ACTION", 10, false},
+		{"DST IP", 15, false}, {"DST PORTS", 15, false}, {"SRC IP", 15, false}, {"SRC PORTS", 15, false},
+	}
+	var states []bboxclient.EnableState
+	var rows [][]string
 	for _, rule := range rules {
-		status := "❌"
-		if rule.Enable == bboxclient.Enabled {
-			status = "✅"
-		}
-
-		// Truncate description to 15 chars
-		desc := truncate(rule.Description, 15)
-
-		// Truncate IPs and ports to 15 chars
-		dstIP := truncate(defaultIfEmpty(rule.DstIP.String(), "ANY"), 15)
-		dstPorts := truncate(defaultIfEmpty(rule.DstPorts.String(), "ANY"), 15)
-		srcIP := truncate(defaultIfEmpty(rule.SrcIP.String(), "ANY"), 15)
-		srcPorts := truncate(defaultIfEmpty(rule.SrcPorts.String(), "ANY"), 15)
-
-		fmt.Printf("[%s] %-3d %-15s %-10s %-15s %-15s %-15s %-15s\n",
-			status,
-			rule.ID,
-			desc,
-			rule.Action,
-			dstIP,
-			dstPorts,
-			srcIP,
-			srcPorts,
-		)
+		states = append(states, rule.Enable)
+		rows = append(rows, []string{
+			fmt.Sprint(rule.ID),
+			fmt.Sprint(rule.Order),
+			rule.Description,
+			string(rule.Action),
+			defaultIfEmpty(rule.DstIP.String(), "ANY"),
+			defaultIfEmpty(rule.DstPorts.String(), "ANY"),
+			defaultIfEmpty(rule.SrcIP.String(), "ANY"),
+			defaultIfEmpty(rule.SrcPorts.String(), "ANY"),
+		})
 	}
+	writeTable(columns, states, rows)
 }
 
 func showFirewallDetail(client *bboxclient.BboxClient, idStr string) {
@@ -130,7 +133,7 @@ func showFirewallDetail(client *bboxclient.BboxClient, idStr string) {
 	fw := client.Firewall()
 	rules, err := fw.GetFirewallRules()
 	if err != nil {
-		log.Fatalf("Error: %v", err)
+		fatalf("Error: %v", err)
 	}
 
 	var rule *bboxclient.FirewallRule
@@ -146,6 +149,19 @@ func showFirewallDetail(client *bboxclient.BboxClient, idStr string) {
 		return
 	}
 
+	if globals.output.structured() {
+		if err := writeStructured(globals.output, rule); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+	if globals.output.delimited() {
+		if err := writeFirewallRules([]bboxclient.FirewallRule{*rule}); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
 	// Display each field on a separate line
 	status := "Disabled"
 	if rule.Enable == 1 {
@@ -165,14 +181,80 @@ func showFirewallDetail(client *bboxclient.BboxClient, idStr string) {
 	fmt.Printf("Dest IP:        %s\n", defaultIfEmpty(rule.DstIP.String(), "ANY"))
 	fmt.Printf("Dest Ports:     %s\n", defaultIfEmpty(rule.DstPorts.String(), "ANY"))
 	fmt.Printf("Protocols:      %s\n", defaultIfEmpty(string(rule.Protocols), "ANY"))
+	if globals.output == outputWide {
+		fmt.Printf("IP Protocol:    %s\n", rule.IPProtocol)
+		fmt.Printf("Negations:      src ip %t, src ports %t, dst ip %t, dst ports %t\n",
+			rule.SrcIPNot == bboxclient.Enabled,
+			rule.SrcPortNot == bboxclient.Enabled,
+			rule.DstIPNot == bboxclient.Enabled,
+			rule.DstPortNot == bboxclient.Enabled,
+		)
+		fmt.Printf("Utilisation:    %d\n", rule.Utilisation)
+	}
 	fmt.Println(repeatString("=", 50))
 }
 
+// writeFirewallRules renders rules in any output format other than the
+// default table
+func writeFirewallRules(rules []bboxclient.FirewallRule) error {
+	if rules == nil {
+		rules = []bboxclient.FirewallRule{}
+	}
+
+	switch {
+	case globals.output.structured():
+		return writeStructured(globals.output, rules)
+	case globals.output.delimited():
+		headers := []string{
+			"id", "enable", "description", "action", "order",
+			"srcipnot", "srcip", "srcportnot", "srcports",
+			"dstipnot", "dstip", "dstportnot", "dstports",
+			"protocols", "ipprotocol", "utilisation",
+		}
+		var rows [][]string
+		for _, r := range rules {
+			rows = append(rows, []string{
+				fmt.Sprint(r.ID), fmt.Sprint(r.Enable), r.Description, string(r.Action), fmt.Sprint(r.Order),
+				fmt.Sprint(r.SrcIPNot), r.SrcIP.String(), fmt.Sprint(r.SrcPortNot), r.SrcPorts.String(),
+				fmt.Sprint(r.DstIPNot), r.DstIP.String(), fmt.Sprint(r.DstPortNot), r.DstPorts.String(),
+				string(r.Protocols), string(r.IPProtocol), fmt.Sprint(r.Utilisation),
+			})
+		}
+		return writeDelimited(globals.output, headers, rows)
+	default:
+		headers := []string{
+			"STATUS", "ID", "ORDER", "DESCRIPTION", "ACTION", "DST IP", "DST PORTS",
+			"SRC IP", "SRC PORTS", "PROTOCOLS", "IP",
+		}
+		var rows [][]string
+		for _, r := range rules {
+			rows = append(rows, []string{
+				statusWord(r.Enable),
+				fmt.Sprint(r.ID),
+				fmt.Sprint(r.Order),
+				r.Description,
+				string(r.Action),
+				negated(defaultIfEmpty(r.DstIP.String(), "ANY"), r.DstIPNot == bboxclient.Enabled),
+				negated(defaultIfEmpty(r.DstPorts.String(), "ANY"), r.DstPortNot == bboxclient.Enabled),
+				negated(defaultIfEmpty(r.SrcIP.String(), "ANY"), r.SrcIPNot == bboxclient.Enabled),
+				negated(defaultIfEmpty(r.SrcPorts.String(), "ANY"), r.SrcPortNot == bboxclient.Enabled),
+				defaultIfEmpty(string(r.Protocols), "ANY"),
+				string(r.IPProtocol),
+			})
+		}
+		return writeWide(headers, rows)
+	}
+}
+
 func deleteFirewallRule(client *bboxclient.BboxClient, ruleID string) {
 	fw := client.Firewall()
 	err := fw.DeleteFirewallRule(ruleID)
+	if errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		fmt.Printf("Error: Rule with ID %s not found\n", ruleID)
+		exit(1)
+	}
 	if err != nil {
-		log.Fatalf("Error deleting firewall rule: %v", err)
+		fatalf("Error deleting firewall rule: %v", err)
 	}
 
 	fmt.Printf("Firewall rule with ID %s deleted successfully\n", ruleID)
@@ -182,47 +264,237 @@ func addFirewallRule(client *bboxclient.BboxClient, rule bboxclient.FirewallRule
 	fw := client.Firewall()
 	err := fw.AddFirewallRule(rule)
 	if err != nil {
-		log.Fatalf("Error adding firewall rule: %v", err)
+		fatalf("Error adding firewall rule: %v", err)
 	}
 
 	fmt.Println("Firewall rule added successfully")
 }
 
-func updateFirewallRule(client *bboxclient.BboxClient, rule bboxclient.FirewallRule) error {
+func editFirewallRule(client *bboxclient.BboxClient, idStr string, args []string) {
+	ruleID, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Printf("Error: invalid ID '%s'\n", idStr)
+		return
+	}
+
+	flags, opts := firewallRuleFlags("firewall edit")
+	flags.Parse(args)
+
 	fw := client.Firewall()
-	return fw.UpdateFirewallRule(rule)
+	rules, err := fw.GetFirewallRules()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	var existingRule *bboxclient.FirewallRule
+	for i := range rules {
+		if rules[i].ID == ruleID {
+			existingRule = &rules[i]
+			break
+		}
+	}
+
+	if existingRule == nil {
+		fmt.Printf("Error: Rule with ID %d not found\n", ruleID)
+		return
+	}
+
+	var rule bboxclient.FirewallRule
+	interactive := false
+	switch {
+	case flags.NFlag() > 0:
+		rule = *existingRule
+		if opts.apply(flags, &rule)["description"] {
+			// Renaming keeps the bboxcli marker so plan/apply still
+			// recognise the rule
+			rule.Description = bboxclient.WithBaseDescription(existingRule.Description, opts.description)
+		}
+	case isInteractive():
+		rule = handleRuleEditing(*existingRule)
+		interactive = true
+	default:
+		fmt.Println("Error: no changes given; pass flags or run from a terminal")
+		exit(1)
+	}
+
+	rule.Normalize()
+	exitOnInvalidRule(rule)
+
+	changes := bboxclient.DiffFirewallRules(*existingRule, rule)
+	if len(changes) == 0 {
+		fmt.Println("No changes")
+		return
+	}
+	if interactive {
+		fmt.Printf("\nChanges to rule %d:\n", ruleID)
+		for _, change := range changes {
+			fmt.Printf("    %s\n", change)
+		}
+		if !confirm("Apply these changes?") {
+			fmt.Println("Aborted, rule left unchanged")
+			return
+		}
+	}
+
+	err = fw.PatchFirewallRule(ruleID, changes)
+	if errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		fmt.Printf("Error: Rule with ID %d not found\n", ruleID)
+		exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error updating firewall rule: %v\n", err)
+		exit(1)
+	}
+	fmt.Println("Firewall rule updated successfully")
+}
+
+// firewallRuleOptions holds the flags shared by "firewall add" and
+// "firewall edit"
+type firewallRuleOptions struct {
+	description string
+	action      string
+	src         string
+	srcPorts    string
+	dst         string
+	dstPorts    string
+	protocols   string
+	ipVersion   string
+	order       int
+	enable      bool
+	disable     bool
+}
+
+func firewallRuleFlags(name string) (*flag.FlagSet, *firewallRuleOptions) {
+	opts := &firewallRuleOptions{}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&opts.description, "description", "", "Rule description")
+	flags.StringVar(&opts.action, "action", "", "Accept or Drop")
+	flags.StringVar(&opts.src, "src", "", "Source IP or network, prefix with ! to negate (empty for ANY)")
+	flags.StringVar(&opts.srcPorts, "src-ports", "", "Source ports, prefix with ! to negate (empty for ANY)")
+	flags.StringVar(&opts.dst, "dst", "", "Destination IP or network, prefix with ! to negate (empty for ANY)")
+	flags.StringVar(&opts.dstPorts, "dst-ports", "", "Destination ports, prefix with ! to negate (empty for ANY)")
+	flags.StringVar(&opts.protocols, "proto", "", "Protocols: tcp, udp or tcp,udp (empty for ANY)")
+	flags.StringVar(&opts.ipVersion, "ip-version", "", "IP version: v4, v6 or both")
+	flags.IntVar(&opts.order, "order", 0, "Rule priority")
+	flags.BoolVar(&opts.enable, "enable", false, "Enable the rule")
+	flags.BoolVar(&opts.disable, "disable", false, "Disable the rule")
+	return flags, opts
+}
+
+// apply copies the flags that were explicitly set on the command line into
+// rule and returns their names. The description is copied as given, without
+// the bboxcli marker.
+func (o *firewallRuleOptions) apply(flags *flag.FlagSet, rule *bboxclient.FirewallRule) map[string]bool {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		switch f.Name {
+		case "description":
+			rule.Description = o.description
+		case "action":
+			rule.Action = parseAction(o.action)
+		case "src":
+			rule.SrcIP, rule.SrcIPNot = parseIPOrPort(o.src)
+		case "src-ports":
+			rule.SrcPorts, rule.SrcPortNot = parseIPOrPort(o.srcPorts)
+		case "dst":
+			rule.DstIP, rule.DstIPNot = parseIPOrPort(o.dst)
+		case "dst-ports":
+			rule.DstPorts, rule.DstPortNot = parseIPOrPort(o.dstPorts)
+		case "proto":
+			rule.Protocols = parseProtocols(o.protocols)
+		case "ip-version":
+			rule.IPProtocol = parseIPVersion(o.ipVersion)
+		case "order":
+			rule.Order = o.order
+		}
+	})
+	if state, ok := enableFlags(flags, o.enable, o.disable); ok {
+		rule.Enable = state
+	}
+	return set
 }
 
-func handleRuleCreation() bboxclient.FirewallRule {
+// handleRuleCreation builds a new rule from the flags, prompting for the
+// values that were not given when running in a terminal
+func handleRuleCreation(flags *flag.FlagSet, opts *firewallRuleOptions) (bboxclient.FirewallRule, error) {
 	rule := bboxclient.FirewallRule{
 		IPProtocol: bboxclient.IPProtocolIPv4,
 		Order:      1,
+		Protocols:  bboxclient.ProtocolAny,
+		Enable:     bboxclient.Enabled,
 	}
+	set := opts.apply(flags, &rule)
 
-	fmt.Println("Creating a new firewall rule.")
+	missing := missingFlags(set)
+	if missing("description") && len(set) == 0 {
+		fmt.Println("Creating a new firewall rule.")
+	}
 
-	rule.Description = bboxclient.GenerateUniqueDescription(readInput("Enter Description: "))
-	rule.Action = bboxclient.Action(readInput("Enter Action (Accept/Drop): "))
-	rule.SrcIP, rule.SrcIPNot = parseIPOrPort(readInput("Enter Source IP (or leave blank for ANY): "))
-	rule.SrcPorts, rule.SrcPortNot = parseIPOrPort(readInput("Enter Source Ports (or leave blank for ANY): "))
-	rule.DstIP, rule.DstIPNot = parseIPOrPort(readInput("Enter Destination IP (or leave blank for ANY): "))
-	rule.DstPorts, rule.DstPortNot = parseIPOrPort(readInput("Enter Destination Ports (or leave blank for ANY): "))
-	rule.Protocols = parseProtocols(readInput("Enter Protocols (tcp/udp or leave blank for ANY): "))
-	rule.Enable = parseEnable(readInput("Enable rule? (y/n): "))
-	return rule
+	if missing("description") {
+		rule.Description = readInput("Enter Description: ")
+	}
+	if missing("action") {
+		rule.Action = parseAction(readInput("Enter Action (Accept/Drop): "))
+	}
+	if missing("src") {
+		rule.SrcIP, rule.SrcIPNot = parseIPOrPort(readInput("Enter Source IP (or leave blank for ANY): "))
+	}
+	if missing("src-ports") {
+		rule.SrcPorts, rule.SrcPortNot = parseIPOrPort(readInput("Enter Source Ports (or leave blank for ANY): "))
+	}
+	if missing("dst") {
+		rule.DstIP, rule.DstIPNot = parseIPOrPort(readInput("Enter Destination IP (or leave blank for ANY): "))
+	}
+	if missing("dst-ports") {
+		rule.DstPorts, rule.DstPortNot = parseIPOrPort(readInput("Enter Destination Ports (or leave blank for ANY): "))
+	}
+	if missing("proto") {
+		rule.Protocols = parseProtocols(readInput("Enter Protocols (tcp/udp or leave blank for ANY): "))
+	}
+	if missing("enable") && missing("disable") {
+		rule.Enable = parseEnable(readInput("Enable rule? (y/n): "))
+	}
+
+	if rule.Description == "" {
+		return rule, errors.New("a description is required (--description)")
+	}
+	if rule.Action == "" {
+		return rule, errors.New("an action is required (--action Accept|Drop)")
+	}
+
+	rule.Description = bboxclient.GenerateUniqueDescription(rule.Description)
+	return rule, nil
 }
 
+// handleRuleEditing prompts for every field of the rule, showing the current
+// value as the default so that pressing Enter keeps it
 func handleRuleEditing(existingRule bboxclient.FirewallRule) bboxclient.FirewallRule {
 	rule := existingRule
 
-	fmt.Println("Editing an existing firewall rule.")
+	fmt.Println("Editing an existing firewall rule. Press Enter to keep the current value, type any for ANY.")
+
+	base, _ := bboxclient.BaseDescription(rule.Description)
+	rule.Description = bboxclient.WithBaseDescription(rule.Description, readInputDefault("Description", base))
+	rule.Action = parseAction(readInputDefault("Action (Accept/Drop)", string(rule.Action)))
+	rule.SrcIP, rule.SrcIPNot = readMatchDefault("Source IP", rule.SrcIP, rule.SrcIPNot)
+	rule.SrcPorts, rule.SrcPortNot = readMatchDefault("Source Ports", rule.SrcPorts, rule.SrcPortNot)
+	rule.DstIP, rule.DstIPNot = readMatchDefault("Destination IP", rule.DstIP, rule.DstIPNot)
+	rule.DstPorts, rule.DstPortNot = readMatchDefault("Destination Ports", rule.DstPorts, rule.DstPortNot)
+	rule.Protocols = parseProtocols(readInputDefault("Protocols (tcp/udp/any)", string(rule.Protocols)))
+	for {
+		order, err := strconv.Atoi(readInputDefault("Order", strconv.Itoa(rule.Order)))
+		if err == nil && order >= 0 {
+			rule.Order = order
+			break
+		}
+		fmt.Println("Order must be a positive number")
+	}
 
-	rule.Action = bboxclient.Action(readInput("Enter Action (Accept/Drop): "))
-	rule.SrcIP, rule.SrcIPNot = parseIPOrPort(readInput("Enter Source IP (or leave blank for ANY): "))
-	rule.SrcPorts, rule.SrcPortNot = parseIPOrPort(readInput("Enter Source Ports (or leave blank for ANY): "))
-	rule.DstIP, rule.DstIPNot = parseIPOrPort(readInput("Enter Destination IP (or leave blank for ANY): "))
-	rule.DstPorts, rule.DstPortNot = parseIPOrPort(readInput("Enter Destination Ports (or leave blank for ANY): "))
-	rule.Protocols = parseProtocols(readInput("Enter Protocols (tcp/udp or leave blank for ANY): "))
-	rule.Enable = parseEnable(readInput("Enable rule? (y/n): "))
+	current := "n"
+	if rule.Enable == bboxclient.Enabled {
+		current = "y"
+	}
+	rule.Enable = parseEnable(readInputDefault("Enable rule? (y/n)", current))
 	return rule
 }
@@ -0,0 +1,367 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	bboxclient "bbox-cli/client"
+)
+
+func handleFirewall6(conn *connection, args []string) {
+	if len(args) < 1 {
+		PrintUsage()
+		return
+	}
+
+	action := args[0]
+
+	switch action {
+	case "show":
+		if len(args) > 1 {
+			showPinholeDetail(conn.Client().Pinhole(), args[1])
+		} else {
+			showPinholeList(conn.Client().Pinhole())
+		}
+	case "add":
+		addPinholeRule(conn, args[1:])
+	case "edit":
+		if len(args) < 2 {
+			PrintUsage()
+			return
+		}
+		editPinholeRule(conn, args[1], args[2:])
+	case "delete":
+		if len(args) < 2 {
+			PrintUsage()
+			return
+		}
+		deletePinholeRule(conn.Client().Pinhole(), args[1])
+	default:
+		fmt.Printf("Unknown firewall6 action: %s\n", action)
+		PrintUsage()
+	}
+}
+
+func showPinholeList(pinhole *bboxclient.PinholeInterface) {
+	rules, err := pinhole.GetPinholeRules()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	if globals.output != outputTable {
+		if err := writePinholeRules(rules); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if len(rules) == 0 {
+		fmt.Println("No IPv6 pinholes found")
+		return
+	}
+
+	// IPv6 addresses need wider columns than the IPv4 table
+	columns := []tableColumn{
+		{"ID", 3, true}, {"DESCRIPTION", 15, false},
+		{"DST IP", 25, false}, {"DST PORTS", 12, false}, {"SRC IP", 25, false}, {"SRC PORTS", 12, false},
+	}
+	var states []bboxclient.EnableState
+	var rows [][]string
+	for _, rule := range rules {
+		states = append(states, rule.Enable)
+		rows = append(rows, []string{
+			fmt.Sprint(rule.ID),
+			rule.Description,
+			rule.DstIP.String(),
+			defaultIfEmpty(rule.DstPorts.String(), "ANY"),
+			defaultIfEmpty(rule.SrcIP.String(), "ANY"),
+			defaultIfEmpty(rule.SrcPorts.String(), "ANY"),
+		})
+	}
+	writeTable(columns, states, rows)
+}
+
+func showPinholeDetail(pinhole *bboxclient.PinholeInterface, idStr string) {
+	ruleID, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Printf("Error: invalid ID '%s'\n", idStr)
+		return
+	}
+
+	rule, err := pinhole.GetPinholeRuleByID(ruleID)
+	if errors.Is(err, bboxclient.ErrPinholeNotFound) {
+		fmt.Printf("Error: IPv6 pinhole with ID %d not found\n", ruleID)
+		exit(1)
+	}
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	if globals.output.structured() {
+		if err := writeStructured(globals.output, rule); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+	if globals.output.delimited() {
+		if err := writePinholeRules([]bboxclient.PinholeRule{rule}); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	status := "Disabled"
+	if rule.Enable == bboxclient.Enabled {
+		status = "Enabled"
+	}
+
+	fmt.Println("\nIPv6 Pinhole Details")
+	fmt.Println(repeatString("=", 50))
+	fmt.Printf("ID:          %d\n", rule.ID)
+	fmt.Printf("Status:      %s\n", status)
+	fmt.Printf("Description: %s\n", rule.Description)
+	fmt.Println(repeatString("-", 50))
+	fmt.Printf("Source IP:      %s\n", negated(defaultIfEmpty(rule.SrcIP.String(), "ANY"), rule.SrcIPNot == bboxclient.Enabled))
+	fmt.Printf("Source Ports:   %s\n", negated(defaultIfEmpty(rule.SrcPorts.String(), "ANY"), rule.SrcPortNot == bboxclient.Enabled))
+	fmt.Printf("Dest IP:        %s\n", rule.DstIP)
+	fmt.Printf("Dest Ports:     %s\n", negated(defaultIfEmpty(rule.DstPorts.String(), "ANY"), rule.DstPortNot == bboxclient.Enabled))
+	fmt.Printf("Protocols:      %s\n", defaultIfEmpty(string(rule.Protocols), "ANY"))
+	fmt.Println(repeatString("=", 50))
+}
+
+// writePinholeRules renders pinholes in any output format other than the
+// default table
+func writePinholeRules(rules []bboxclient.PinholeRule) error {
+	if rules == nil {
+		rules = []bboxclient.PinholeRule{}
+	}
+
+	switch {
+	case globals.output.structured():
+		return writeStructured(globals.output, rules)
+	case globals.output.delimited():
+		headers := []string{
+			"id", "enable", "description",
+			"srcipnot", "srcip", "srcportnot", "srcports",
+			"dstip", "dstportnot", "dstports", "protocols",
+		}
+		var rows [][]string
+		for _, r := range rules {
+			rows = append(rows, []string{
+				fmt.Sprint(r.ID), fmt.Sprint(r.Enable), r.Description,
+				fmt.Sprint(r.SrcIPNot), r.SrcIP.String(), fmt.Sprint(r.SrcPortNot), r.SrcPorts.String(),
+				r.DstIP.String(), fmt.Sprint(r.DstPortNot), r.DstPorts.String(), string(r.Protocols),
+			})
+		}
+		return writeDelimited(globals.output, headers, rows)
+	default:
+		headers := []string{
+			"STATUS", "ID", "DESCRIPTION", "DST IP", "DST PORTS", "SRC IP", "SRC PORTS", "PROTOCOLS",
+		}
+		var rows [][]string
+		for _, r := range rules {
+			rows = append(rows, []string{
+				statusWord(r.Enable),
+				fmt.Sprint(r.ID),
+				r.Description,
+				r.DstIP.String(),
+				negated(defaultIfEmpty(r.DstPorts.String(), "ANY"), r.DstPortNot == bboxclient.Enabled),
+				negated(defaultIfEmpty(r.SrcIP.String(), "ANY"), r.SrcIPNot == bboxclient.Enabled),
+				negated(defaultIfEmpty(r.SrcPorts.String(), "ANY"), r.SrcPortNot == bboxclient.Enabled),
+				defaultIfEmpty(string(r.Protocols), "ANY"),
+			})
+		}
+		return writeWide(headers, rows)
+	}
+}
+
+func addPinholeRule(conn *connection, args []string) {
+	flags, opts := pinholeRuleFlags("firewall6 add")
+	flags.Parse(args)
+
+	rule, err := handlePinholeCreation(flags, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+	rule.Normalize()
+	exitOnValidationError(fmt.Sprintf("IPv6 pinhole %q", rule.Description), rule.Validate())
+
+	if err := conn.Client().Pinhole().AddPinholeRule(rule); err != nil {
+		fatalf("Error adding IPv6 pinhole: %v", err)
+	}
+	fmt.Println("IPv6 pinhole added successfully")
+}
+
+func editPinholeRule(conn *connection, idStr string, args []string) {
+	ruleID, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Printf("Error: invalid ID '%s'\n", idStr)
+		exit(1)
+	}
+
+	flags, opts := pinholeRuleFlags("firewall6 edit")
+	flags.Parse(args)
+
+	pinhole := conn.Client().Pinhole()
+	rule, err := pinhole.GetPinholeRuleByID(ruleID)
+	if errors.Is(err, bboxclient.ErrPinholeNotFound) {
+		fmt.Printf("Error: IPv6 pinhole with ID %d not found\n", ruleID)
+		exit(1)
+	}
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	switch {
+	case flags.NFlag() > 0:
+		opts.apply(flags, &rule)
+	case isInteractive():
+		rule = handlePinholeEditing(rule)
+	default:
+		fmt.Println("Error: no changes given; pass flags or run from a terminal")
+		exit(1)
+	}
+
+	rule.Normalize()
+	exitOnValidationError(fmt.Sprintf("IPv6 pinhole %q", rule.Description), rule.Validate())
+
+	if err := pinhole.UpdatePinholeRule(rule); err != nil {
+		fatalf("Error updating IPv6 pinhole: %v", err)
+	}
+	fmt.Println("IPv6 pinhole updated successfully")
+}
+
+func deletePinholeRule(pinhole *bboxclient.PinholeInterface, ruleID string) {
+	err := pinhole.DeletePinholeRule(ruleID)
+	if errors.Is(err, bboxclient.ErrPinholeNotFound) {
+		fmt.Printf("Error: IPv6 pinhole with ID %s not found\n", ruleID)
+		exit(1)
+	}
+	if err != nil {
+		fatalf("Error deleting IPv6 pinhole: %v", err)
+	}
+	fmt.Printf("IPv6 pinhole with ID %s deleted successfully\n", ruleID)
+}
+
+// pinholeRuleOptions holds the flags shared by "firewall6 add" and
+// "firewall6 edit"
+type pinholeRuleOptions struct {
+	description string
+	src         string
+	srcPorts    string
+	dst         string
+	dstPorts    string
+	protocols   string
+	enable      bool
+	disable     bool
+}
+
+func pinholeRuleFlags(name string) (*flag.FlagSet, *pinholeRuleOptions) {
+	opts := &pinholeRuleOptions{}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&opts.description, "description", "", "Pinhole description")
+	flags.StringVar(&opts.src, "src", "", "Remote IPv6 address or prefix, prefix with ! to negate (empty for ANY)")
+	flags.StringVar(&opts.srcPorts, "src-ports", "", "Source ports, prefix with ! to negate (empty for ANY)")
+	flags.StringVar(&opts.dst, "dst", "", "IPv6 address or prefix of the LAN host")
+	flags.StringVar(&opts.dstPorts, "dst-ports", "", "Destination ports, prefix with ! to negate (empty for ANY)")
+	flags.StringVar(&opts.protocols, "proto", "", "Protocols: tcp, udp or tcp,udp (empty for ANY)")
+	flags.BoolVar(&opts.enable, "enable", false, "Enable the pinhole")
+	flags.BoolVar(&opts.disable, "disable", false, "Disable the pinhole")
+	return flags, opts
+}
+
+// apply copies the flags that were explicitly set on the command line into
+// rule, leaving the other fields untouched. It returns the names of the
+// flags that were set.
+func (o *pinholeRuleOptions) apply(flags *flag.FlagSet, rule *bboxclient.PinholeRule) map[string]bool {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		switch f.Name {
+		case "description":
+			rule.Description = o.description
+		case "src":
+			rule.SrcIP, rule.SrcIPNot = parseIPOrPort(o.src)
+		case "src-ports":
+			rule.SrcPorts, rule.SrcPortNot = parseIPOrPort(o.srcPorts)
+		case "dst":
+			rule.DstIP = bboxclient.StringOrInt(o.dst)
+		case "dst-ports":
+			rule.DstPorts, rule.DstPortNot = parseIPOrPort(o.dstPorts)
+		case "proto":
+			rule.Protocols = parseProtocols(o.protocols)
+		}
+	})
+	if state, ok := enableFlags(flags, o.enable, o.disable); ok {
+		rule.Enable = state
+	}
+	return set
+}
+
+// handlePinholeCreation builds a pinhole from the flags and, on a terminal,
+// prompts for the values that were not given
+func handlePinholeCreation(flags *flag.FlagSet, opts *pinholeRuleOptions) (bboxclient.PinholeRule, error) {
+	rule := bboxclient.PinholeRule{
+		Protocols: bboxclient.ProtocolAny,
+		Enable:    bboxclient.Enabled,
+	}
+	set := opts.apply(flags, &rule)
+
+	missing := missingFlags(set)
+	if missing("description") && len(set) == 0 {
+		fmt.Println("Creating a new IPv6 pinhole.")
+	}
+
+	if missing("description") {
+		rule.Description = readInput("Enter Description: ")
+	}
+	if missing("dst") {
+		rule.DstIP = bboxclient.StringOrInt(readInput("Enter LAN host IPv6 address or prefix: "))
+	}
+	if missing("dst-ports") {
+		rule.DstPorts, rule.DstPortNot = parseIPOrPort(readInput("Enter Destination Ports (or leave blank for ANY): "))
+	}
+	if missing("src") {
+		rule.SrcIP, rule.SrcIPNot = parseIPOrPort(readInput("Enter Source IPv6 (or leave blank for ANY): "))
+	}
+	if missing("src-ports") {
+		rule.SrcPorts, rule.SrcPortNot = parseIPOrPort(readInput("Enter Source Ports (or leave blank for ANY): "))
+	}
+	if missing("proto") {
+		rule.Protocols = parseProtocols(readInput("Enter Protocols (tcp/udp or leave blank for ANY): "))
+	}
+	if missing("enable") && missing("disable") {
+		rule.Enable = parseEnable(readInput("Enable pinhole? (y/n): "))
+	}
+
+	if rule.Description == "" {
+		return rule, errors.New("a description is required (--description)")
+	}
+	if rule.DstIP == "" {
+		return rule, errors.New("a LAN host is required (--dst)")
+	}
+	return rule, nil
+}
+
+func handlePinholeEditing(existingRule bboxclient.PinholeRule) bboxclient.PinholeRule {
+	rule := existingRule
+
+	fmt.Println("Editing an existing IPv6 pinhole. Press Enter to keep the current value, type any for ANY.")
+
+	rule.Description = readInputDefault("Enter Description", rule.Description)
+	rule.DstIP = bboxclient.StringOrInt(readInputDefault("Enter LAN host IPv6 address or prefix", rule.DstIP.String()))
+	rule.DstPorts, rule.DstPortNot = readMatchDefault("Enter Destination Ports", rule.DstPorts, rule.DstPortNot)
+	rule.SrcIP, rule.SrcIPNot = readMatchDefault("Enter Source IPv6", rule.SrcIP, rule.SrcIPNot)
+	rule.SrcPorts, rule.SrcPortNot = readMatchDefault("Enter Source Ports", rule.SrcPorts, rule.SrcPortNot)
+	rule.Protocols = parseProtocols(readInputDefault("Enter Protocols", string(rule.Protocols)))
+
+	current := "n"
+	if rule.Enable == bboxclient.Enabled {
+		current = "y"
+	}
+	rule.Enable = parseEnable(readInputDefault("Enable pinhole? (y/n)", current))
+	return rule
+}
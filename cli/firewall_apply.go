@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bboxclient "bbox-cli/client"
+
+	"gopkg.in/yaml.v3"
+)
+
+// handleFirewallApply implements both "firewall plan" and "firewall apply".
+// The plan is always printed; it is only executed when apply is true.
+func handleFirewallApply(conn *connection, args []string, apply bool) {
+	name := "firewall plan"
+	if apply {
+		name = "firewall apply"
+	}
+
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	file := flags.String("f", "", "YAML or JSON file with the desired firewall rules")
+	prune := flags.Bool("prune", false, "Also delete rules not created by bboxcli")
+	flags.Parse(args)
+
+	if *file == "" {
+		fmt.Printf("Error: %s requires -f <file>\n", name)
+		exit(1)
+	}
+
+	desired, err := loadFirewallRules(*file)
+	if err != nil {
+		fatalf("Error reading %s: %v", *file, err)
+	}
+	for i := range desired {
+		desired[i].Normalize()
+		exitOnInvalidRule(desired[i].WithDefaults())
+	}
+
+	fw := conn.Client().Firewall()
+	current, err := fw.GetFirewallRules()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	plan, err := bboxclient.PlanFirewallRules(current, desired, *prune)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	printFirewallPlan(plan)
+	if !apply || plan.Empty() {
+		return
+	}
+
+	if err := fw.ApplyFirewallPlan(plan); err != nil {
+		fatalf("Error applying plan: %v", err)
+	}
+	fmt.Println("Firewall rules applied successfully")
+}
+
+// loadFirewallRules reads a rule file. Files ending in .json are decoded as
+// JSON, anything else as YAML. Both use the same layout as the API, except
+// that enable may be written as true/false and defaults to enabled:
+//
+//	rules:
+//	  - description: ssh
+//	    action: Accept
+//	    dstports: 22
+func loadFirewallRules(path string) ([]bboxclient.FirewallRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Rules []fileRule `json:"rules" yaml:"rules"`
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]bboxclient.FirewallRule, len(file.Rules))
+	for i, rule := range file.Rules {
+		rules[i] = bboxclient.FirewallRule(rule)
+	}
+	return rules, nil
+}
+
+// fileRule is a rule as written in a rule file, where a missing enable means
+// the rule is wanted enabled rather than disabled
+type fileRule bboxclient.FirewallRule
+
+func (r *fileRule) UnmarshalJSON(data []byte) error {
+	*r = fileRule{Enable: bboxclient.Enabled}
+	return json.Unmarshal(data, (*bboxclient.FirewallRule)(r))
+}
+
+func (r *fileRule) UnmarshalYAML(node *yaml.Node) error {
+	*r = fileRule{Enable: bboxclient.Enabled}
+	return node.Decode((*bboxclient.FirewallRule)(r))
+}
+
+func printFirewallPlan(plan bboxclient.FirewallPlan) {
+	if plan.Empty() {
+		fmt.Println("No changes. Firewall rules are up to date.")
+		return
+	}
+
+	for _, step := range plan.Steps {
+		base, _ := bboxclient.BaseDescription(step.Rule.Description)
+		switch step.Action {
+		case bboxclient.PlanCreate:
+			fmt.Printf("+ create %s\n", base)
+		case bboxclient.PlanUpdate:
+			fmt.Printf("~ update %s (ID %d)\n", base, step.Rule.ID)
+			for _, change := range step.Changes {
+				fmt.Printf("    %s\n", change)
+			}
+		case bboxclient.PlanDelete:
+			fmt.Printf("- delete %s (ID %d)\n", step.Rule.Description, step.Rule.ID)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Plan: %d to create, %d to update, %d to delete\n",
+		plan.Count(bboxclient.PlanCreate),
+		plan.Count(bboxclient.PlanUpdate),
+		plan.Count(bboxclient.PlanDelete),
+	)
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func writeRuleFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFirewallRulesEnable(t *testing.T) {
+	yamlFile := writeRuleFile(t, "rules.yaml", `rules:
+  - description: ssh
+    action: Accept
+    dstports: 22
+  - description: web
+    action: Accept
+    dstports: 80
+    enable: false
+  - description: dns
+    action: Accept
+    dstports: 53
+    enable: true
+  - description: ntp
+    action: Accept
+    dstports: 123
+    enable: 0
+`)
+	jsonFile := writeRuleFile(t, "rules.json", `{"rules": [
+  {"description": "ssh", "action": "Accept", "dstports": 22},
+  {"description": "web", "action": "Accept", "dstports": 80, "enable": false},
+  {"description": "dns", "action": "Accept", "dstports": 53, "enable": true},
+  {"description": "ntp", "action": "Accept", "dstports": 123, "enable": 0}
+]}`)
+
+	want := map[string]bboxclient.EnableState{
+		"ssh": bboxclient.Enabled,
+		"web": bboxclient.Disabled,
+		"dns": bboxclient.Enabled,
+		"ntp": bboxclient.Disabled,
+	}
+	for _, path := range []string{yamlFile, jsonFile} {
+		rules, err := loadFirewallRules(path)
+		if err != nil {
+			t.Fatalf("loadFirewallRules(%s): %v", filepath.Base(path), err)
+		}
+
+		plan, err := bboxclient.PlanFirewallRules(nil, rules, false)
+		if err != nil {
+			t.Fatalf("PlanFirewallRules: %v", err)
+		}
+		if len(plan.Steps) != len(want) {
+			t.Fatalf("%s: got %d steps, want %d", filepath.Base(path), len(plan.Steps), len(want))
+		}
+		for _, step := range plan.Steps {
+			base, _ := bboxclient.BaseDescription(step.Rule.Description)
+			if step.Action != bboxclient.PlanCreate || step.Rule.Enable != want[base] {
+				t.Errorf("%s: %s planned as %v with enable %d, want create with enable %d",
+					filepath.Base(path), base, step.Action, step.Rule.Enable, want[base])
+			}
+		}
+	}
+}
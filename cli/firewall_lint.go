@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	bboxclient "bbox-cli/client"
+)
+
+// handleFirewallLint implements "firewall lint". It exits with status 1 when
+// an error is reported so it can gate CI jobs; warnings alone exit 0.
+func handleFirewallLint(conn *connection, args []string) {
+	flags := flag.NewFlagSet("firewall lint", flag.ExitOnError)
+	ignore := flags.String("ignore", "", "Comma-separated checks to skip, e.g. disabled,wide-accept")
+	disabledFor := flags.Duration("disabled-for", 30*24*time.Hour, "Report disabled rules left unchanged for this long")
+	flags.Parse(args)
+
+	ignored, err := parseLintChecks(*ignore)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	rules, err := conn.Client().Firewall().GetFirewallRules()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	// The router does not date its rules, so the ages come from the journal
+	changes, err := loadJournal()
+	if err != nil {
+		fatalf("Error reading the journal: %v", err)
+	}
+	opts := bboxclient.LintOptions{
+		LastChanged: bboxclient.LastChanged(changes, conn.profile.Name, bboxclient.SnapshotFirewall),
+		DisabledFor: *disabledFor,
+	}
+
+	findings := []bboxclient.LintFinding{}
+	for _, f := range bboxclient.LintFirewallRules(rules, opts) {
+		if !ignored[f.Check] {
+			findings = append(findings, f)
+		}
+	}
+
+	switch {
+	case globals.output.structured():
+		err = writeStructured(globals.output, findings)
+	case globals.output.delimited():
+		var rows [][]string
+		for _, f := range findings {
+			rows = append(rows, []string{string(f.Check), string(f.Severity), fmt.Sprint(f.RuleID), fmt.Sprint(f.Related), f.Message})
+		}
+		err = writeDelimited(globals.output, []string{"check", "severity", "rule", "related", "message"}, rows)
+	default:
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		if len(findings) == 0 {
+			fmt.Println("No problems found")
+		} else {
+			fmt.Printf("\n%d problem(s) found in %d rules\n", len(findings), len(rules))
+		}
+	}
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	if bboxclient.LintFailed(findings) {
+		exit(1)
+	}
+}
+
+// parseLintChecks parses the comma-separated --ignore list, rejecting names
+// that are not checks
+func parseLintChecks(list string) (map[bboxclient.LintCheck]bool, error) {
+	known := make(map[bboxclient.LintCheck]bool)
+	var names []string
+	for _, check := range bboxclient.LintChecks() {
+		known[check] = true
+		names = append(names, string(check))
+	}
+
+	checks := make(map[bboxclient.LintCheck]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[bboxclient.LintCheck(name)] {
+			return nil, fmt.Errorf("unknown check %q (want %s)", name, strings.Join(names, ", "))
+		}
+		checks[bboxclient.LintCheck(name)] = true
+	}
+	return checks, nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	bboxclient "bbox-cli/client"
+)
+
+// handleFirewallMove implements "firewall move <id> --before|--after|--to"
+func handleFirewallMove(conn *connection, args []string) {
+	if len(args) < 1 {
+		PrintUsage()
+		return
+	}
+	ruleID, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("Error: invalid ID '%s'\n", args[0])
+		exit(1)
+	}
+
+	flags := flag.NewFlagSet("firewall move", flag.ExitOnError)
+	before := flags.Int("before", 0, "Place the rule right before the rule with this ID")
+	after := flags.Int("after", 0, "Place the rule right after the rule with this ID")
+	to := flags.Int("to", 0, "Place the rule at this position, 1 being applied first")
+	dryRun := flags.Bool("dry-run", false, "Only print the new orders")
+	flags.Parse(args[1:])
+
+	var targets []string
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "before" || f.Name == "after" || f.Name == "to" {
+			targets = append(targets, f.Name)
+		}
+	})
+	if len(targets) != 1 {
+		fmt.Println("Error: firewall move needs exactly one of --before, --after or --to")
+		exit(1)
+	}
+	target := targets[0]
+	if (target == "before" && *before < 1) || (target == "after" && *after < 1) {
+		fmt.Printf("Error: --%s needs a rule ID\n", target)
+		exit(1)
+	}
+
+	fw := conn.Client().Firewall()
+	rules, err := fw.GetFirewallRules()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	var plan bboxclient.FirewallPlan
+	switch target {
+	case "before":
+		plan, err = bboxclient.PlanFirewallMoveRelative(rules, ruleID, *before, false)
+	case "after":
+		plan, err = bboxclient.PlanFirewallMoveRelative(rules, ruleID, *after, true)
+	default:
+		plan, err = bboxclient.PlanFirewallMove(rules, ruleID, *to)
+	}
+	applyOrderPlan(fw, plan, err, *dryRun)
+}
+
+// handleFirewallReorder implements "firewall reorder [id...]"
+func handleFirewallReorder(conn *connection, args []string) {
+	flags := flag.NewFlagSet("firewall reorder", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Only print the new orders")
+	flags.Parse(args)
+
+	var ids []int
+	for _, arg := range flags.Args() {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Printf("Error: invalid ID '%s'\n", arg)
+			exit(1)
+		}
+		ids = append(ids, id)
+	}
+
+	fw := conn.Client().Firewall()
+	rules, err := fw.GetFirewallRules()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	plan, err := bboxclient.PlanFirewallReorder(rules, ids)
+	applyOrderPlan(fw, plan, err, *dryRun)
+}
+
+// applyOrderPlan prints the order changes of plan and pushes them unless
+// dryRun is set
+func applyOrderPlan(fw *bboxclient.FirewallInterface, plan bboxclient.FirewallPlan, err error, dryRun bool) {
+	if errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	printFirewallPlan(plan)
+	if dryRun || plan.Empty() {
+		return
+	}
+
+	if err := fw.ApplyFirewallPlan(plan); err != nil {
+		fatalf("Error applying new order: %v", err)
+	}
+	fmt.Println("Firewall rules reordered successfully")
+}
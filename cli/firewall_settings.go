@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	bboxclient "bbox-cli/client"
+)
+
+func showFirewallStatus(client *bboxclient.BboxClient) {
+	settings, err := client.Firewall().GetFirewallSettings()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	if globals.output.structured() {
+		if err := writeStructured(globals.output, settings); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+	if globals.output.delimited() {
+		headers := []string{"enable", "level", "pingresponder", "gamermode"}
+		row := []string{
+			fmt.Sprint(settings.Enable), string(settings.Level),
+			fmt.Sprint(settings.PingResponder), fmt.Sprint(settings.GamerMode),
+		}
+		if err := writeDelimited(globals.output, headers, [][]string{row}); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("Firewall:       %s\n", statusWord(settings.Enable))
+	fmt.Printf("Level:          %s\n", settings.Level)
+	fmt.Printf("Ping responder: %s\n", onOff(settings.PingResponder))
+	fmt.Printf("Gamer mode:     %s\n", onOff(settings.GamerMode))
+}
+
+func setFirewallLevel(client *bboxclient.BboxClient, level string) {
+	if err := client.Firewall().SetFirewallLevel(bboxclient.FirewallLevel(strings.ToLower(level))); err != nil {
+		fatalf("Error: %v", err)
+	}
+	fmt.Printf("Firewall level set to %s\n", strings.ToLower(level))
+}
+
+// setFirewallSwitch handles "firewall ping-responder" and "firewall
+// gamer-mode", which both take on or off
+func setFirewallSwitch(client *bboxclient.BboxClient, name, value string) {
+	state, ok := parseOnOff(value)
+	if !ok {
+		fmt.Printf("Error: %s expects on or off, got '%s'\n", name, value)
+		exit(1)
+	}
+
+	fw := client.Firewall()
+	var err error
+	var label string
+	switch name {
+	case "ping-responder":
+		err, label = fw.SetPingResponder(state), "Ping responder"
+	case "gamer-mode":
+		err, label = fw.SetGamerMode(state), "Gamer mode"
+	}
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	fmt.Printf("%s turned %s\n", label, onOff(state))
+}
+
+func onOff(state bboxclient.EnableState) string {
+	if state == bboxclient.Enabled {
+		return "on"
+	}
+	return "off"
+}
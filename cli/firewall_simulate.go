@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	bboxclient "bbox-cli/client"
+)
+
+// simulationResult is the structured output of "firewall test"
+type simulationResult struct {
+	Matched bool                      `json:"matched" yaml:"matched"`
+	Action  bboxclient.Action         `json:"action,omitempty" yaml:"action,omitempty"`
+	Rule    *bboxclient.FirewallRule  `json:"rule,omitempty" yaml:"rule,omitempty"`
+	Others  []bboxclient.FirewallRule `json:"also_matches,omitempty" yaml:"also_matches,omitempty"`
+}
+
+// handleFirewallTest implements "firewall test", which evaluates the current
+// rules against a packet without sending any traffic
+func handleFirewallTest(conn *connection, args []string) {
+	flags := flag.NewFlagSet("firewall test", flag.ExitOnError)
+	src := flags.String("src", "", "Source address of the packet")
+	dst := flags.String("dst", "", "Destination address of the packet")
+	sport := flags.String("sport", "", "Source port of the packet")
+	dport := flags.String("dport", "", "Destination port of the packet")
+	proto := flags.String("proto", "", "Protocol of the packet: tcp or udp")
+	ipVersion := flags.String("ip", "", "IP version of the packet: v4 or v6 (default from the addresses, else v4)")
+	flags.Parse(args)
+
+	packet, err := parsePacket(*src, *dst, *sport, *dport, *proto, *ipVersion)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	rules, err := conn.Client().Firewall().GetFirewallRules()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	matches, err := bboxclient.FirewallMatches(rules, packet)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	var result simulationResult
+	if len(matches) > 0 {
+		result = simulationResult{
+			Matched: true,
+			Action:  matches[0].Action,
+			Rule:    &matches[0],
+			Others:  matches[1:],
+		}
+	}
+
+	if globals.output.structured() {
+		if err := writeStructured(globals.output, result); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if !result.Matched {
+		fmt.Println("No rule matches; the default policy of the firewall level applies")
+		return
+	}
+	base, _ := bboxclient.BaseDescription(result.Rule.Description)
+	fmt.Printf("Matched rule %d (%s) at order %d\n", result.Rule.ID, base, result.Rule.Order)
+	fmt.Printf("Action: %s\n", result.Action)
+	for _, rule := range result.Others {
+		base, _ := bboxclient.BaseDescription(rule.Description)
+		fmt.Printf("  also matches rule %d (%s, %s) at order %d\n", rule.ID, base, rule.Action, rule.Order)
+	}
+}
+
+// parsePacket builds the packet described by the "firewall test" flags
+func parsePacket(src, dst, sport, dport, proto, ipVersion string) (bboxclient.Packet, error) {
+	var packet bboxclient.Packet
+	var err error
+
+	if src != "" {
+		if packet.SrcIP, err = netip.ParseAddr(src); err != nil {
+			return packet, fmt.Errorf("invalid --src %q", src)
+		}
+	}
+	if dst != "" {
+		if packet.DstIP, err = netip.ParseAddr(dst); err != nil {
+			return packet, fmt.Errorf("invalid --dst %q", dst)
+		}
+	}
+	if packet.SrcIP.IsValid() && packet.DstIP.IsValid() && packet.SrcIP.Unmap().Is4() != packet.DstIP.Unmap().Is4() {
+		return packet, fmt.Errorf("--src and --dst are not the same IP version")
+	}
+
+	for _, addr := range []netip.Addr{packet.SrcIP, packet.DstIP} {
+		if addr.IsValid() {
+			packet.IPv6 = !addr.Unmap().Is4()
+		}
+	}
+	switch parseIPVersion(ipVersion) {
+	case "":
+	case bboxclient.IPProtocolIPv4:
+		if packet.IPv6 {
+			return packet, fmt.Errorf("--ip v4 given with IPv6 addresses")
+		}
+	case bboxclient.IPProtocolIPv6:
+		if (packet.SrcIP.IsValid() || packet.DstIP.IsValid()) && !packet.IPv6 {
+			return packet, fmt.Errorf("--ip v6 given with IPv4 addresses")
+		}
+		packet.IPv6 = true
+	default:
+		return packet, fmt.Errorf("invalid --ip %q, expected v4 or v6", ipVersion)
+	}
+
+	for _, p := range []struct {
+		name  string
+		value string
+		dst   *int
+	}{
+		{"--sport", sport, &packet.SrcPort},
+		{"--dport", dport, &packet.DstPort},
+	} {
+		if p.value == "" {
+			continue
+		}
+		port, err := strconv.Atoi(p.value)
+		if err != nil || port < 1 || port > 65535 {
+			return packet, fmt.Errorf("invalid %s %q", p.name, p.value)
+		}
+		*p.dst = port
+	}
+
+	switch bboxclient.Protocol(strings.ToLower(proto)) {
+	case "":
+	case bboxclient.ProtocolTCP, bboxclient.ProtocolUDP:
+		packet.Protocol = bboxclient.Protocol(strings.ToLower(proto))
+	default:
+		return packet, fmt.Errorf("invalid --proto %q, expected tcp or udp", proto)
+	}
+
+	return packet, nil
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func TestFirewallRuleOptionsEnable(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bboxclient.EnableState
+	}{
+		{nil, bboxclient.Disabled},
+		{[]string{"--enable"}, bboxclient.Enabled},
+		{[]string{"--enable=false"}, bboxclient.Disabled},
+		{[]string{"--disable"}, bboxclient.Disabled},
+		{[]string{"--disable=false"}, bboxclient.Enabled},
+	}
+	for _, tt := range tests {
+		for _, start := range []bboxclient.EnableState{bboxclient.Enabled, bboxclient.Disabled} {
+			flags, opts := firewallRuleFlags("firewall edit")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			rule := bboxclient.FirewallRule{Enable: start}
+			opts.apply(flags, &rule)
+
+			want := tt.want
+			if tt.args == nil {
+				want = start
+			}
+			if rule.Enable != want {
+				t.Errorf("%v on a rule with enable %d: enable = %d, want %d", tt.args, start, rule.Enable, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	bboxclient "bbox-cli/client"
+)
+
+// handleHistory implements "bboxcli history": the changes recorded for the
+// current profile, newest first
+func handleHistory(conn *connection, args []string) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	all := flags.Bool("all", false, "Show the changes of every profile")
+	limit := flags.Int("n", 20, "Number of changes to show, 0 for all")
+	flags.Parse(args)
+
+	changes, err := loadJournal()
+	if err != nil {
+		fatalf("Error reading the journal: %v", err)
+	}
+
+	shown := []bboxclient.Change{}
+	for i := len(changes) - 1; i >= 0; i-- {
+		if *limit > 0 && len(shown) == *limit {
+			break
+		}
+		if *all || changes[i].Profile == conn.profile.Name {
+			shown = append(shown, changes[i])
+		}
+	}
+
+	if globals.output.structured() {
+		if err := writeStructured(globals.output, shown); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	headers := []string{"SEQ", "TIME", "PROFILE", "USER", "CHANGE", "STATUS"}
+	var rows [][]string
+	for _, c := range shown {
+		status := ""
+		switch {
+		case c.UndoneAt != nil:
+			status = "undone " + c.UndoneAt.Local().Format("2006-01-02 15:04")
+		case c.Note != "":
+			status = c.Note
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(c.Seq),
+			c.Time.Local().Format("2006-01-02 15:04:05"),
+			c.Profile,
+			c.User,
+			c.String(),
+			status,
+		})
+	}
+
+	switch {
+	case globals.output.delimited():
+		err = writeDelimited(globals.output, headers, rows)
+	case len(rows) == 0:
+		fmt.Println("No changes recorded")
+		if !globals.journal && !conn.profile.Journal {
+			fmt.Println("Changes are only recorded with --journal or journal: true in the profile")
+		}
+	default:
+		err = writeWide(headers, rows)
+	}
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+}
+
+// handleUndo implements "bboxcli undo [n]", which reverts the last n changes
+// of the current profile that are not undone yet, newest first
+func handleUndo(conn *connection, args []string) {
+	flags := flag.NewFlagSet("undo", flag.ExitOnError)
+	force := flags.Bool("force", false, "Undo even if the rules were changed since")
+	dryRun := flags.Bool("dry-run", false, "Only print what would be undone")
+	positional := parseInterspersed(flags, args)
+
+	count := 1
+	if len(positional) > 1 {
+		fmt.Println("Error: undo takes at most one argument")
+		exit(1)
+	}
+	if len(positional) == 1 {
+		n, err := strconv.Atoi(positional[0])
+		if err != nil || n < 1 {
+			fmt.Printf("Error: invalid number of changes %q\n", positional[0])
+			exit(1)
+		}
+		count = n
+	}
+
+	changes, err := loadJournal()
+	if err != nil {
+		fatalf("Error reading the journal: %v", err)
+	}
+
+	var pending []int
+	for i := len(changes) - 1; i >= 0 && len(pending) < count; i-- {
+		if changes[i].Profile == conn.profile.Name && changes[i].UndoneAt == nil {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Println("Nothing to undo")
+		return
+	}
+	if len(pending) < count {
+		fmt.Printf("Only %d changes can be undone\n", len(pending))
+	}
+
+	if *dryRun {
+		for _, i := range pending {
+			fmt.Printf("Would undo #%d: %s\n", changes[i].Seq, changes[i])
+		}
+		return
+	}
+
+	client := conn.Client()
+	for _, i := range pending {
+		change := changes[i]
+		newID, err := client.UndoChange(change, *force)
+		if errors.Is(err, bboxclient.ErrChangeConflict) {
+			fmt.Printf("Error: cannot undo #%d: %v\n", change.Seq, err)
+			fmt.Println("Use --force to undo it anyway")
+			exit(1)
+		}
+		if err != nil {
+			fatalf("Error undoing #%d: %v", change.Seq, err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		changes[i].UndoneAt = &now
+		// Earlier changes of a re-created rule now refer to its new ID
+		if newID != 0 && newID != change.RuleID {
+			for j := 0; j < i; j++ {
+				if changes[j].Profile == change.Profile && changes[j].Section == change.Section {
+					changes[j].ReplaceRuleID(change.RuleID, newID)
+				}
+			}
+		}
+		if err := saveJournal(changes); err != nil {
+			fatalf("Error updating the journal: %v", err)
+		}
+
+		fmt.Printf("Undone #%d: %s\n", change.Seq, change)
+		if newID != 0 && newID != change.RuleID {
+			fmt.Printf("  re-created as ID %d\n", newID)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	bboxclient "bbox-cli/client"
+)
+
+// journalPath returns the file recording the changes made by bboxcli,
+// honouring BBOXCLI_JOURNAL and XDG_STATE_HOME
+func journalPath() (string, error) {
+	if path := os.Getenv("BBOXCLI_JOURNAL"); path != "" {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "bboxcli", "journal.jsonl"), nil
+}
+
+// loadJournal reads every recorded change, oldest first. A missing journal
+// is empty.
+func loadJournal() ([]bboxclient.Change, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+	return readJournal(path)
+}
+
+func readJournal(path string) ([]bboxclient.Change, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var changes []bboxclient.Change
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var change bboxclient.Change
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, scanner.Err()
+}
+
+// saveJournal rewrites the journal loaded by loadJournal, e.g. to mark
+// changes as undone. Changes other runs appended since are kept.
+func saveJournal(changes []bboxclient.Change) error {
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockJournal(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := readJournal(path)
+	if err != nil {
+		return err
+	}
+	if len(current) > len(changes) {
+		changes = append(changes[:len(changes):len(changes)], current[len(changes):]...)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".journal-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, change := range changes {
+		if err := enc.Encode(change); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fileJournal appends the changes made through a client to the journal,
+// tagged with the profile and the local user
+type fileJournal struct {
+	profile string
+	user    string
+}
+
+func newFileJournal(profile Profile) *fileJournal {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return &fileJournal{profile: profile.Name, user: name}
+}
+
+// Record implements bboxclient.Journal. The change is already made, so a
+// journal that cannot be written only produces a warning.
+func (j *fileJournal) Record(change bboxclient.Change) {
+	if err := j.append(change); err != nil {
+		log.Printf("Warning: could not record %s in the journal: %v", change, err)
+	}
+}
+
+func (j *fileJournal) append(change bboxclient.Change) error {
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockJournal(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if change.Seq, err = nextSeq(path); err != nil {
+		return err
+	}
+	change.Profile, change.User = j.profile, j.user
+
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lockJournal takes the lock that serialises the writes to the journal at
+// path, also creating its directory. The returned function releases it.
+func lockJournal(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
+
+// nextSeq returns the sequence number of the next change. The last number is
+// kept in a file next to the journal so that the journal itself is only
+// read when that file is missing. The caller holds the journal lock.
+func nextSeq(path string) (int, error) {
+	seqPath := path + ".seq"
+	data, err := os.ReadFile(seqPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		changes, err := readJournal(path)
+		if err != nil {
+			return 0, err
+		}
+		last = 0
+		for _, c := range changes {
+			if c.Seq > last {
+				last = c.Seq
+			}
+		}
+	}
+
+	if err := os.WriteFile(seqPath, []byte(strconv.Itoa(last+1)+"\n"), 0o600); err != nil {
+		return 0, err
+	}
+	return last + 1, nil
+}
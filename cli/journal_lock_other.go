@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cli
+
+import "os"
+
+// lockFile does nothing where flock is not available, so concurrent runs may
+// give their changes the same sequence number
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func TestFileJournalConcurrentAppends(t *testing.T) {
+	t.Setenv("BBOXCLI_JOURNAL", filepath.Join(t.TempDir(), "journal.jsonl"))
+	journal := newFileJournal(Profile{Name: "home"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if err := journal.append(bboxclient.Change{Section: bboxclient.SnapshotFirewall, RuleID: id}); err != nil {
+				t.Errorf("append: %v", err)
+			}
+		}(i + 1)
+	}
+	wg.Wait()
+
+	changes, err := loadJournal()
+	if err != nil {
+		t.Fatalf("loadJournal: %v", err)
+	}
+	seen := make(map[int]bool)
+	for _, c := range changes {
+		if seen[c.Seq] {
+			t.Errorf("sequence number %d used twice", c.Seq)
+		}
+		seen[c.Seq] = true
+	}
+	if len(changes) != 20 || !seen[1] || !seen[20] {
+		t.Errorf("got %d changes numbered %v, want 1 to 20", len(changes), seen)
+	}
+}
+
+func TestSaveJournalKeepsNewChanges(t *testing.T) {
+	t.Setenv("BBOXCLI_JOURNAL", filepath.Join(t.TempDir(), "journal.jsonl"))
+	journal := newFileJournal(Profile{Name: "home"})
+
+	if err := journal.append(bboxclient.Change{RuleID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := loadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another run records a change between loading and saving
+	if err := journal.append(bboxclient.Change{RuleID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	changes[0].Note = "edited"
+	if err := saveJournal(changes); err != nil {
+		t.Fatalf("saveJournal: %v", err)
+	}
+
+	changes, err = loadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Note != "edited" || changes[1].RuleID != 2 || changes[1].Seq != 2 {
+		t.Errorf("journal after save = %+v", changes)
+	}
+}
@@ -0,0 +1,272 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// keyringService is the Secret Service attribute, and file section, under
+// which bboxcli stores passwords
+const keyringService = "bboxcli"
+
+// errNoStoredPassword is returned by keyrings holding no password for a
+// profile
+var errNoStoredPassword = errors.New("no password stored")
+
+// keyring stores router passwords by profile name
+type keyring interface {
+	Name() string
+	Get(profile string) (string, error)
+	Set(profile, password string) error
+	Delete(profile string) error
+}
+
+// openKeyring returns the keyring selected by BBOXCLI_KEYRING, "secret-service"
+// or "file". By default the Secret Service is used when secret-tool and a
+// D-Bus session are available, and the encrypted file otherwise.
+func openKeyring() (keyring, error) {
+	switch backend := os.Getenv("BBOXCLI_KEYRING"); backend {
+	case "secret-service":
+		return secretServiceKeyring{}, nil
+	case "file":
+		return newFileKeyring()
+	case "":
+		if _, err := exec.LookPath("secret-tool"); err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+			return secretServiceKeyring{}, nil
+		}
+		return newFileKeyring()
+	default:
+		return nil, fmt.Errorf("unknown keyring %q, want secret-service or file", backend)
+	}
+}
+
+// secretServiceKeyring uses the desktop secret store (GNOME Keyring, KWallet,
+// KeePassXC...) through secret-tool, the libsecret client of the Secret
+// Service D-Bus API
+type secretServiceKeyring struct{}
+
+func (secretServiceKeyring) Name() string {
+	return "Secret Service"
+}
+
+func (secretServiceKeyring) Get(profile string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", keyringService, "profile", profile)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", err
+	}
+	// secret-tool exits with status 1 and no output for missing items
+	if len(out) == 0 && (err == nil || stderr.Len() == 0) {
+		return "", errNoStoredPassword
+	}
+	if err != nil {
+		return "", fmt.Errorf("secret-tool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+func (secretServiceKeyring) Set(profile, password string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "store",
+		"--label", fmt.Sprintf("bboxcli password (%s)", profile),
+		"service", keyringService, "profile", profile)
+	cmd.Stdin = strings.NewReader(password)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (secretServiceKeyring) Delete(profile string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "clear", "service", keyringService, "profile", profile)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// keyringIterations is the PBKDF2 work factor of new keyring files
+const keyringIterations = 600000
+
+// fileKeyring keeps passwords in a file encrypted with AES-256-GCM under a
+// key derived from a passphrase, taken from BBOXCLI_KEYRING_PASSPHRASE or
+// asked for in the terminal
+type fileKeyring struct {
+	path string
+	data keyringFile
+}
+
+// keyringFile is the layout of the keyring file. The profile name is bound
+// to each sealed password as additional data.
+type keyringFile struct {
+	Salt       []byte                  `json:"salt"`
+	Iterations int                     `json:"iterations"`
+	Entries    map[string]sealedSecret `json:"entries"`
+}
+
+type sealedSecret struct {
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func newFileKeyring() (*fileKeyring, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	k := &fileKeyring{path: filepath.Join(dir, "bboxcli", "keyring.json")}
+
+	data, err := os.ReadFile(k.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &k.data); err != nil {
+		return nil, fmt.Errorf("%s: %w", k.path, err)
+	}
+	return k, nil
+}
+
+func (k *fileKeyring) Name() string {
+	return "encrypted file " + k.path
+}
+
+func (k *fileKeyring) Get(profile string) (string, error) {
+	entry, ok := k.data.Entries[profile]
+	if !ok {
+		return "", errNoStoredPassword
+	}
+	passphrase, err := keyringPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := k.cipher(passphrase)
+	if err != nil {
+		return "", err
+	}
+	plain, err := gcm.Open(nil, entry.Nonce, entry.Data, []byte(profile))
+	if err != nil {
+		return "", errors.New("wrong keyring passphrase or corrupted keyring")
+	}
+	return string(plain), nil
+}
+
+func (k *fileKeyring) Set(profile, password string) error {
+	fresh := len(k.data.Entries) == 0
+	passphrase, err := keyringPassphrase(fresh)
+	if err != nil {
+		return err
+	}
+	if fresh {
+		k.data = keyringFile{Iterations: keyringIterations, Entries: map[string]sealedSecret{}}
+		k.data.Salt = make([]byte, 16)
+		if _, err := rand.Read(k.data.Salt); err != nil {
+			return err
+		}
+	}
+
+	gcm, err := k.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+	// Every entry shares the key: check the passphrase against an existing
+	// one rather than lock it out
+	for name, entry := range k.data.Entries {
+		if _, err := gcm.Open(nil, entry.Nonce, entry.Data, []byte(name)); err != nil {
+			return errors.New("wrong keyring passphrase")
+		}
+		break
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	k.data.Entries[profile] = sealedSecret{
+		Nonce: nonce,
+		Data:  gcm.Seal(nil, nonce, []byte(password), []byte(profile)),
+	}
+	return k.save()
+}
+
+// Delete needs no passphrase since the other entries are left as they are
+func (k *fileKeyring) Delete(profile string) error {
+	if _, ok := k.data.Entries[profile]; !ok {
+		return nil
+	}
+	delete(k.data.Entries, profile)
+	return k.save()
+}
+
+func (k *fileKeyring) save() error {
+	data, err := json.MarshalIndent(k.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o700); err != nil {
+		return err
+	}
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.path)
+}
+
+func (k *fileKeyring) cipher(passphrase string) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(passphrase), k.data.Salt, k.data.Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyringPassphrase reads the passphrase of the keyring file, asking twice
+// when it is being created
+func keyringPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv("BBOXCLI_KEYRING_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if !isInteractive() {
+		return "", errors.New("the keyring passphrase is needed: set BBOXCLI_KEYRING_PASSPHRASE")
+	}
+
+	passphrase, err := readSecret("Keyring passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("empty keyring passphrase")
+	}
+	if confirm {
+		again, err := readSecret("Repeat the passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("the passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+)
+
+// useFileKeyring points the file keyring to a temporary directory and sets
+// its passphrase
+func useFileKeyring(t *testing.T, passphrase string) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("BBOXCLI_KEYRING", "file")
+	t.Setenv("BBOXCLI_KEYRING_PASSPHRASE", passphrase)
+}
+
+func TestFileKeyring(t *testing.T) {
+	useFileKeyring(t, "correct horse")
+
+	ring, err := newFileKeyring()
+	if err != nil {
+		t.Fatalf("newFileKeyring: %v", err)
+	}
+	if err := ring.Set("home", "home-secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := ring.Set("work", "work-secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Read back from the file
+	ring, err = newFileKeyring()
+	if err != nil {
+		t.Fatalf("newFileKeyring: %v", err)
+	}
+	for profile, want := range map[string]string{"home": "home-secret", "work": "work-secret"} {
+		if got, err := ring.Get(profile); err != nil || got != want {
+			t.Errorf("Get(%q) = %q, %v; want %q", profile, got, err, want)
+		}
+	}
+	if _, err := ring.Get("other"); !errors.Is(err, errNoStoredPassword) {
+		t.Errorf("Get(other) err = %v, want errNoStoredPassword", err)
+	}
+
+	t.Setenv("BBOXCLI_KEYRING_PASSPHRASE", "wrong")
+	if got, err := ring.Get("home"); err == nil {
+		t.Errorf("Get with the wrong passphrase = %q, want an error", got)
+	}
+	if err := ring.Set("other", "secret"); err == nil {
+		t.Error("Set with the wrong passphrase succeeded")
+	}
+	if _, ok := ring.data.Entries["other"]; ok {
+		t.Error("Set with the wrong passphrase stored the password")
+	}
+
+	if err := ring.Delete("home"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := ring.Get("home"); !errors.Is(err, errNoStoredPassword) {
+		t.Errorf("Get after Delete err = %v, want errNoStoredPassword", err)
+	}
+}
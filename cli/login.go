@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+)
+
+// handleLogin implements "bboxcli login": it checks the password against the
+// router and stores it in the keyring so later commands need no other
+// password source
+func handleLogin(conn *connection, args []string) {
+	flags := flag.NewFlagSet("login", flag.ExitOnError)
+	flags.Parse(args)
+
+	password, ok, err := explicitPassword()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	if !ok {
+		if !isInteractive() {
+			fmt.Println("Error: login needs a terminal, --password-stdin or --password-file")
+			exit(1)
+		}
+		if password, err = readSecret(fmt.Sprintf("Password for %s: ", conn.profile.URL)); err != nil {
+			fatalf("Error: %v", err)
+		}
+	}
+
+	if err := conn.open(password, true); err != nil {
+		fatalf("Authentication failed: %v", err)
+	}
+
+	ring, err := openKeyring()
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	if err := ring.Set(conn.profile.Name, password); err != nil {
+		fatalf("Error storing the password in the %s: %v", ring.Name(), err)
+	}
+	fmt.Printf("Logged in to %s, password of profile %q stored in the %s\n",
+		conn.profile.URL, conn.profile.Name, ring.Name())
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+)
+
+// handleLogout implements "bboxcli logout": it ends the cached session on the
+// router and removes it from the cache, and with --forget also the password
+// stored by "bboxcli login"
+func handleLogout(conn *connection, args []string) {
+	flags := flag.NewFlagSet("logout", flag.ExitOnError)
+	forget := flags.Bool("forget", false, "Also remove the password from the keyring")
+	flags.Parse(args)
+
+	if session, ok := loadSession(conn.profile); ok {
+		client := conn.newClient()
+		client.Auth().Resume(session, "")
+		if err := client.Auth().Logout(); err != nil {
+			log.Printf("Warning: could not end the session on the router: %v", err)
+		}
+	}
+	if err := deleteSession(conn.profile); err != nil {
+		fatalf("Error removing the cached session: %v", err)
+	}
+
+	if *forget {
+		ring, err := openKeyring()
+		if err != nil {
+			fatalf("Error: %v", err)
+		}
+		if err := ring.Delete(conn.profile.Name); err != nil {
+			fatalf("Error removing the password from the %s: %v", ring.Name(), err)
+		}
+	}
+	fmt.Printf("Logged out of %s\n", conn.profile.URL)
+}
@@ -1,41 +1,62 @@
 package cli
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"strconv"
 
 	bboxclient "bbox-cli/client"
 )
 
-func handleNat(client *bboxclient.BboxClient, args []string) {
+func handleNat(conn *connection, args []string) {
 	if len(args) < 1 {
 		PrintUsage()
 		return
 	}
 
-	nat := client.Nat()
 	action := args[0]
 
 	switch action {
 	case "show":
 		if len(args) > 1 {
-			showNatDetail(nat, args[1])
+			showNatDetail(conn.Client().Nat(), args[1])
 		} else {
 			// Show list view
-			showNatList(nat)
+			showNatList(conn.Client().Nat())
 		}
 	case "enable":
 		if len(args) < 2 {
 			PrintUsage()
 			return
 		}
-		nat.EnableNatRule(args[1])
+		if err := conn.Client().Nat().EnableNatRule(args[1]); err != nil {
+			fatalf("Error enabling NAT rule: %v", err)
+		}
+		fmt.Printf("NAT rule with ID %s enabled\n", args[1])
 	case "disable":
 		if len(args) < 2 {
 			PrintUsage()
 			return
 		}
-		nat.DisableNatRule(args[1])
+		if err := conn.Client().Nat().DisableNatRule(args[1]); err != nil {
+			fatalf("Error disabling NAT rule: %v", err)
+		}
+		fmt.Printf("NAT rule with ID %s disabled\n", args[1])
+	case "add":
+		addNatRule(conn, args[1:])
+	case "edit":
+		if len(args) < 2 {
+			PrintUsage()
+			return
+		}
+		editNatRule(conn.Client().Nat(), args[1], args[2:])
+	case "delete":
+		if len(args) < 2 {
+			PrintUsage()
+			return
+		}
+		deleteNatRule(conn.Client().Nat(), args[1])
 	default:
 		fmt.Printf("Unknown nat action: %s\n", action)
 		PrintUsage()
@@ -45,7 +66,14 @@ func handleNat(client *bboxclient.BboxClient, args []string) {
 func showNatList(nat *bboxclient.NatInterface) {
 	rules, err := nat.GetNatRules()
 	if err != nil {
-		log.Fatalf("Error: %v", err)
+		fatalf("Error: %v", err)
+	}
+
+	if globals.output != outputTable {
+		if err := writeNatRules(rules); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
 	}
 
 	if len(rules) == 0 {
@@ -53,41 +81,30 @@ func showNatList(nat *bboxclient.NatInterface) {
 		return
 	}
 
-	fmt.Printf("%-4s %-3s %-15s %-15s %-15s %-15s %-15s\n",
-		"", "ID", "DESCRIPTION", "DST IP", "DST PORTS", "SRC IP", "SRC PORTS")
-	fmt.Println(repeatString("-", 85))
-
+	columns := []tableColumn{
+		{"ID", 3, true}, {"DESCRIPTION", 15, false},
+		{"DST IP", 15, false}, {"DST PORTS", 15, false}, {"SRC IP", 15, false}, {"SRC PORTS", 15, false},
+	}
+	var states []bboxclient.EnableState
+	var rows [][]string
 	for _, rule := range rules {
-		status := "❌"
-		if rule.Enable == bboxclient.Enabled {
-			status = "✅"
-		}
-
-		// Truncate description to 15 chars
-		desc := truncate(rule.Description, 15)
-
-		// Truncate IPs and ports to 15 chars
-		srcIP := truncate(defaultIfEmpty(rule.SrcIP.String(), "ANY"), 15)
-		srcPorts := truncate(defaultIfEmpty(rule.SrcPorts.String(), "ANY"), 15)
-		dstIP := truncate(defaultIfEmpty(rule.TargetIP.String(), "ANY"), 15)
-		dstPorts := truncate(defaultIfEmpty(rule.TargetPorts.String(), "ANY"), 15)
-
-		fmt.Printf("[%s] %-3d %-15s %-15s %-15s %-15s %-15s\n",
-			status,
-			rule.ID,
-			desc,
-			dstIP,
-			dstPorts,
-			srcIP,
-			srcPorts,
-		)
+		states = append(states, rule.Enable)
+		rows = append(rows, []string{
+			fmt.Sprint(rule.ID),
+			rule.Description,
+			defaultIfEmpty(rule.TargetIP.String(), "ANY"),
+			defaultIfEmpty(rule.TargetPorts.String(), "ANY"),
+			defaultIfEmpty(rule.SrcIP.String(), "ANY"),
+			defaultIfEmpty(rule.SrcPorts.String(), "ANY"),
+		})
 	}
+	writeTable(columns, states, rows)
 }
 
 func showNatDetail(nat *bboxclient.NatInterface, id string) {
 	rules, err := nat.GetNatRules()
 	if err != nil {
-		log.Fatalf("Error: %v", err)
+		fatalf("Error: %v", err)
 	}
 
 	var ruleFound *bboxclient.NatRule
@@ -103,6 +120,19 @@ func showNatDetail(nat *bboxclient.NatInterface, id string) {
 		return
 	}
 
+	if globals.output.structured() {
+		if err := writeStructured(globals.output, ruleFound); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+	if globals.output.delimited() {
+		if err := writeNatRules([]bboxclient.NatRule{*ruleFound}); err != nil {
+			fatalf("Error: %v", err)
+		}
+		return
+	}
+
 	fmt.Printf("NAT Rule ID: %d\n", ruleFound.ID)
 	fmt.Printf("Description: %s\n", ruleFound.Description)
 	fmt.Printf("Enabled: %t\n", ruleFound.Enable == bboxclient.Enabled)
@@ -110,4 +140,238 @@ func showNatDetail(nat *bboxclient.NatInterface, id string) {
 	fmt.Printf("Source Ports: %s\n", ruleFound.SrcPorts.String())
 	fmt.Printf("Target IP: %s\n", ruleFound.TargetIP.String())
 	fmt.Printf("Target Ports: %s\n", ruleFound.TargetPorts.String())
+	if globals.output == outputWide {
+		fmt.Printf("Protocol: %s\n", ruleFound.Protocol)
+	}
+}
+
+// writeNatRules renders rules in any output format other than the default
+// table
+func writeNatRules(rules []bboxclient.NatRule) error {
+	if rules == nil {
+		rules = []bboxclient.NatRule{}
+	}
+
+	switch {
+	case globals.output.structured():
+		return writeStructured(globals.output, rules)
+	case globals.output.delimited():
+		headers := []string{
+			"id", "enable", "description", "protocol",
+			"externalip", "externalport", "internalip", "internalport",
+		}
+		var rows [][]string
+		for _, r := range rules {
+			rows = append(rows, []string{
+				fmt.Sprint(r.ID), fmt.Sprint(r.Enable), r.Description, string(r.Protocol),
+				r.SrcIP.String(), r.SrcPorts.String(), r.TargetIP.String(), r.TargetPorts.String(),
+			})
+		}
+		return writeDelimited(globals.output, headers, rows)
+	default:
+		headers := []string{
+			"STATUS", "ID", "DESCRIPTION", "PROTOCOL", "DST IP", "DST PORTS", "SRC IP", "SRC PORTS",
+		}
+		var rows [][]string
+		for _, r := range rules {
+			rows = append(rows, []string{
+				statusWord(r.Enable),
+				fmt.Sprint(r.ID),
+				r.Description,
+				defaultIfEmpty(string(r.Protocol), "ANY"),
+				defaultIfEmpty(r.TargetIP.String(), "ANY"),
+				defaultIfEmpty(r.TargetPorts.String(), "ANY"),
+				defaultIfEmpty(r.SrcIP.String(), "ANY"),
+				defaultIfEmpty(r.SrcPorts.String(), "ANY"),
+			})
+		}
+		return writeWide(headers, rows)
+	}
+}
+
+func addNatRule(conn *connection, args []string) {
+	flags, opts := natRuleFlags("nat add")
+	flags.Parse(args)
+
+	rule, err := handleNatRuleCreation(flags, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+	rule.Normalize()
+	exitOnValidationError(fmt.Sprintf("NAT rule %q", rule.Description), rule.Validate())
+
+	if err := conn.Client().Nat().AddNatRule(rule); err != nil {
+		fatalf("Error adding NAT rule: %v", err)
+	}
+	fmt.Println("NAT rule added successfully")
+}
+
+func editNatRule(nat *bboxclient.NatInterface, idStr string, args []string) {
+	ruleID, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Printf("Error: invalid ID '%s'\n", idStr)
+		exit(1)
+	}
+
+	flags, opts := natRuleFlags("nat edit")
+	flags.Parse(args)
+
+	rule, err := nat.GetNatRuleByID(ruleID)
+	if errors.Is(err, bboxclient.ErrNatRuleNotFound) {
+		fmt.Printf("Error: NAT rule with ID %d not found\n", ruleID)
+		exit(1)
+	}
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	switch {
+	case flags.NFlag() > 0:
+		opts.apply(flags, &rule)
+	case isInteractive():
+		rule = handleNatRuleEditing(rule)
+	default:
+		fmt.Println("Error: no changes given; pass flags or run from a terminal")
+		exit(1)
+	}
+	rule.Normalize()
+	exitOnValidationError(fmt.Sprintf("NAT rule %q", rule.Description), rule.Validate())
+
+	if err := nat.UpdateNatRule(rule); err != nil {
+		fatalf("Error updating NAT rule: %v", err)
+	}
+	fmt.Println("NAT rule updated successfully")
+}
+
+func deleteNatRule(nat *bboxclient.NatInterface, ruleID string) {
+	err := nat.DeleteNatRule(ruleID)
+	if errors.Is(err, bboxclient.ErrNatRuleNotFound) {
+		fmt.Printf("NAT rule with ID %s not found\n", ruleID)
+		exit(1)
+	}
+	if err != nil {
+		fatalf("Error deleting NAT rule: %v", err)
+	}
+	fmt.Printf("NAT rule with ID %s deleted successfully\n", ruleID)
+}
+
+// natRuleOptions holds the flags shared by "nat add" and "nat edit"
+type natRuleOptions struct {
+	description  string
+	protocol     string
+	externalIP   string
+	externalPort string
+	internalIP   string
+	internalPort string
+	enable       bool
+	disable      bool
+}
+
+func natRuleFlags(name string) (*flag.FlagSet, *natRuleOptions) {
+	opts := &natRuleOptions{}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&opts.description, "description", "", "Rule description")
+	flags.StringVar(&opts.protocol, "proto", "", "Protocol (tcp, udp or tcp,udp)")
+	flags.StringVar(&opts.externalIP, "external-ip", "", "Allowed remote IP (empty or any for ANY)")
+	flags.StringVar(&opts.externalPort, "external-port", "", "Port or range exposed on the WAN side")
+	flags.StringVar(&opts.internalIP, "internal-ip", "", "LAN host receiving the traffic")
+	flags.StringVar(&opts.internalPort, "internal-port", "", "Port or range on the LAN host")
+	flags.BoolVar(&opts.enable, "enable", false, "Enable the rule")
+	flags.BoolVar(&opts.disable, "disable", false, "Disable the rule")
+	return flags, opts
+}
+
+// apply copies the flags that were explicitly set on the command line into
+// rule, leaving the other fields untouched, and returns their names.
+func (o *natRuleOptions) apply(flags *flag.FlagSet, rule *bboxclient.NatRule) map[string]bool {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		switch f.Name {
+		case "description":
+			rule.Description = o.description
+		case "proto":
+			rule.Protocol = parseProtocols(o.protocol)
+		case "external-ip":
+			rule.SrcIP = parseAny(o.externalIP)
+		case "external-port":
+			rule.SrcPorts = parseAny(o.externalPort)
+		case "internal-ip":
+			rule.TargetIP = bboxclient.StringOrInt(o.internalIP)
+		case "internal-port":
+			rule.TargetPorts = bboxclient.StringOrInt(o.internalPort)
+		}
+	})
+	if state, ok := enableFlags(flags, o.enable, o.disable); ok {
+		rule.Enable = state
+	}
+	return set
+}
+
+// handleNatRuleCreation builds a new NAT rule from the flags, prompting for
+// the values that were not given when running in a terminal
+func handleNatRuleCreation(flags *flag.FlagSet, opts *natRuleOptions) (bboxclient.NatRule, error) {
+	rule := bboxclient.NatRule{
+		Enable:   bboxclient.Enabled,
+		Protocol: bboxclient.ProtocolAny,
+	}
+	set := opts.apply(flags, &rule)
+
+	missing := missingFlags(set)
+	if missing("description") && len(set) == 0 {
+		fmt.Println("Creating a new NAT rule.")
+	}
+
+	if missing("description") {
+		rule.Description = readInput("Enter Description: ")
+	}
+	if missing("proto") {
+		rule.Protocol = parseProtocols(readInput("Enter Protocol (tcp/udp or leave blank for ANY): "))
+	}
+	if missing("external-ip") {
+		rule.SrcIP = parseAny(readInput("Enter External IP (or leave blank for ANY): "))
+	}
+	if missing("external-port") {
+		rule.SrcPorts = parseAny(readInput("Enter External Ports: "))
+	}
+	if missing("internal-ip") {
+		rule.TargetIP = bboxclient.StringOrInt(readInput("Enter Internal IP: "))
+	}
+	if missing("internal-port") {
+		rule.TargetPorts = bboxclient.StringOrInt(readInput("Enter Internal Ports: "))
+	}
+	if missing("enable") && missing("disable") {
+		rule.Enable = parseEnable(readInput("Enable rule? (y/n): "))
+	}
+
+	if rule.TargetIP == "" {
+		return rule, errors.New("an internal IP is required (--internal-ip)")
+	}
+	if rule.TargetPorts == "" {
+		return rule, errors.New("an internal port is required (--internal-port)")
+	}
+	return rule, nil
+}
+
+// handleNatRuleEditing prompts for every field of the rule, showing the
+// current value as the default so that pressing Enter keeps it
+func handleNatRuleEditing(existingRule bboxclient.NatRule) bboxclient.NatRule {
+	rule := existingRule
+
+	fmt.Println("Editing an existing NAT rule. Press Enter to keep the current value, type any for ANY.")
+
+	rule.Description = readInputDefault("Enter Description", rule.Description)
+	rule.Protocol = parseProtocols(readInputDefault("Enter Protocol (tcp/udp/any)", string(rule.Protocol)))
+	rule.SrcIP = readAnyDefault("Enter External IP", rule.SrcIP)
+	rule.SrcPorts = readAnyDefault("Enter External Ports", rule.SrcPorts)
+	rule.TargetIP = bboxclient.StringOrInt(readInputDefault("Enter Internal IP", rule.TargetIP.String()))
+	rule.TargetPorts = bboxclient.StringOrInt(readInputDefault("Enter Internal Ports", rule.TargetPorts.String()))
+
+	current := "n"
+	if rule.Enable == bboxclient.Enabled {
+		current = "y"
+	}
+	rule.Enable = parseEnable(readInputDefault("Enable rule? (y/n)", current))
+	return rule
 }
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"time"
+
+	bboxclient "bbox-cli/client"
+)
+
+// globalOptions holds the flags accepted by every command, wherever they
+// appear on the command line
+type globalOptions struct {
+	output  outputFormat
+	profile string
+	url     string
+	timeout time.Duration
+	retries int
+
+	passwordFile  string
+	passwordStdin bool
+
+	journal bool
+}
+
+var globals = globalOptions{
+	output:  outputTable,
+	timeout: bboxclient.DefaultTimeout,
+	retries: 2,
+}
+
+func globalFlagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet("bboxcli", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Var(&globals.output, "output", "Output format: table, wide, json, yaml, csv or tsv")
+	flags.StringVar(&globals.profile, "profile", "", "Configuration profile to use")
+	flags.StringVar(&globals.url, "url", "", "API root of the router, overriding the profile")
+	flags.DurationVar(&globals.timeout, "timeout", globals.timeout, "Time limit of each request to the router")
+	flags.IntVar(&globals.retries, "retries", globals.retries, "Retries of requests failing while the router is busy")
+	flags.StringVar(&globals.passwordFile, "password-file", "", "File holding the router password")
+	flags.BoolVar(&globals.passwordStdin, "password-stdin", false, "Read the router password from stdin")
+	flags.BoolVar(&globals.journal, "journal", false, "Record rule changes for history and undo")
+	return flags
+}
+
+// parseGlobalFlags removes the global flags from args, applies them to
+// globals and returns the remaining arguments for the subcommands.
+func parseGlobalFlags(args []string) ([]string, error) {
+	flags := globalFlagSet()
+
+	var global, rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if name == arg || name == "" {
+			rest = append(rest, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(name, "=")
+
+		f := flags.Lookup(name)
+		if f == nil {
+			rest = append(rest, arg)
+			continue
+		}
+
+		global = append(global, arg)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			global = append(global, args[i])
+		}
+	}
+
+	if err := flags.Parse(global); err != nil {
+		return nil, err
+	}
+	if globals.retries < 0 {
+		return nil, errors.New("--retries must not be negative")
+	}
+	return rest, nil
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	bboxclient "bbox-cli/client"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormat selects how show commands render their results
+type outputFormat string
+
+const (
+	outputTable outputFormat = "table"
+	outputWide  outputFormat = "wide"
+	outputJSON  outputFormat = "json"
+	outputYAML  outputFormat = "yaml"
+	outputCSV   outputFormat = "csv"
+	outputTSV   outputFormat = "tsv"
+)
+
+func (o *outputFormat) String() string {
+	return string(*o)
+}
+
+func (o *outputFormat) Set(value string) error {
+	switch f := outputFormat(strings.ToLower(value)); f {
+	case outputTable, outputWide, outputJSON, outputYAML, outputCSV, outputTSV:
+		*o = f
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (want table, wide, json, yaml, csv or tsv)", value)
+	}
+}
+
+// structured reports whether the format serialises whole objects rather than
+// a list of columns
+func (o outputFormat) structured() bool {
+	return o == outputJSON || o == outputYAML
+}
+
+// delimited reports whether the format is a CSV-like record format
+func (o outputFormat) delimited() bool {
+	return o == outputCSV || o == outputTSV
+}
+
+// writeStructured prints v as indented JSON or YAML depending on format
+func writeStructured(format outputFormat, v interface{}) error {
+	if format == outputYAML {
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(v)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeDelimited prints a header line followed by one record per row, using
+// commas for csv and tabs for tsv
+func writeDelimited(format outputFormat, headers []string, rows [][]string) error {
+	w := csv.NewWriter(os.Stdout)
+	if format == outputTSV {
+		w.Comma = '\t'
+	}
+
+	if err := w.Write(headers); err != nil {
+		return err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// writeWide prints an aligned table without truncating any column
+func writeWide(headers []string, rows [][]string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// tableColumn is one column of the default tables. Values wider than width
+// are truncated unless full is set.
+type tableColumn struct {
+	header string
+	width  int
+	full   bool
+}
+
+// writeTable prints the default table: the status icon of each row followed
+// by its values padded to the column widths
+func writeTable(columns []tableColumn, states []bboxclient.EnableState, rows [][]string) {
+	format := func(prefix string, values []string) string {
+		line := prefix
+		for i, c := range columns {
+			value := values[i]
+			if !c.full {
+				value = truncate(value, c.width)
+			}
+			line += fmt.Sprintf(" %-*s", c.width, value)
+		}
+		return line
+	}
+
+	headers := make([]string, len(columns))
+	width := 4
+	for i, c := range columns {
+		headers[i] = c.header
+		width += c.width + 1
+	}
+	// The icon is two columns wide, so "[✅]" lines up with four spaces
+	fmt.Println(format("    ", headers))
+	fmt.Println(repeatString("-", width))
+	for i, row := range rows {
+		fmt.Println(format("["+statusIcon(states[i])+"]", row))
+	}
+}
+
+// negated prefixes value with "!" when the matching *Not flag is set
+func negated(value string, not bool) string {
+	if not && value != "" {
+		return "!" + value
+	}
+	return value
+}
+
+// statusIcon is the status column of the default tables
+func statusIcon(state bboxclient.EnableState) string {
+	if state == bboxclient.Enabled {
+		return "✅"
+	}
+	return "❌"
+}
+
+// statusWord is the status column of the wide tables
+func statusWord(state bboxclient.EnableState) string {
+	if state == bboxclient.Enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
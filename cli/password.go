@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// resolvePassword finds the router password of the profile. The sources are
+// tried in order: --password-stdin, --password-file, the environment variable
+// of the profile (which .env can set), its password_command and finally the
+// keyring filled by "bboxcli login".
+func resolvePassword(profile Profile) (string, error) {
+	if password, ok, err := explicitPassword(); ok || err != nil {
+		return password, err
+	}
+	if password := os.Getenv(profile.PasswordEnv); password != "" {
+		return password, nil
+	}
+	if profile.PasswordCommand != "" {
+		return commandPassword(profile.PasswordCommand)
+	}
+
+	ring, err := openKeyring()
+	if err != nil {
+		return "", err
+	}
+	password, err := ring.Get(profile.Name)
+	if errors.Is(err, errNoStoredPassword) {
+		return "", fmt.Errorf("no password for profile %q: run bboxcli login, or use --password-file, "+
+			"--password-stdin, password_command or %s", profile.Name, profile.PasswordEnv)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading the password from the %s: %w", ring.Name(), err)
+	}
+	return password, nil
+}
+
+// explicitPassword reads the password given with --password-stdin or
+// --password-file, reporting whether either was used
+func explicitPassword() (string, bool, error) {
+	switch {
+	case globals.passwordStdin:
+		line, err := stdin.ReadString('\n')
+		password := trimNewline(line)
+		if password == "" {
+			if err == nil {
+				err = errors.New("empty password")
+			}
+			return "", true, fmt.Errorf("reading the password from stdin: %w", err)
+		}
+		return password, true, nil
+	case globals.passwordFile != "":
+		password, err := filePassword(globals.passwordFile)
+		return password, true, err
+	}
+	return "", false, nil
+}
+
+// filePassword reads the first line of a password file, warning when other
+// users can read it
+func filePassword(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s is accessible by other users, restrict it with chmod 600\n", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	if password := trimNewline(line); password != "" {
+		return password, nil
+	}
+	return "", fmt.Errorf("%s: empty password", path)
+}
+
+// commandPassword runs the password_command of a profile through the shell,
+// e.g. "pass show bbox", and returns the first line it prints
+func commandPassword(command string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password_command: %w", err)
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	if password := trimNewline(line); password != "" {
+		return password, nil
+	}
+	return "", errors.New("password_command printed no password")
+}
+
+// readSecret prompts on stderr and reads a line without echoing it
+func readSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(secret), err
+}
+
+// trimNewline removes the line ending but keeps any other whitespace, which
+// may be part of the password
+func trimNewline(s string) string {
+	return strings.TrimRight(s, "\r\n")
+}
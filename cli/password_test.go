@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePasswordOrder(t *testing.T) {
+	useFileKeyring(t, "passphrase")
+	ring, err := newFileKeyring()
+	if err != nil {
+		t.Fatalf("newFileKeyring: %v", err)
+	}
+	if err := ring.Set("home", "from-keyring"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("from-file\nignored\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_BBOX_PWD", "from-env")
+
+	savedGlobals, savedStdin := globals, stdin
+	t.Cleanup(func() { globals, stdin = savedGlobals, savedStdin })
+	globals.passwordStdin = true
+	globals.passwordFile = passwordFile
+	stdin = bufio.NewReader(strings.NewReader("from-stdin\n"))
+
+	profile := Profile{Name: "home", PasswordEnv: "TEST_BBOX_PWD", PasswordCommand: "echo from-command"}
+
+	// Each step removes the source that won the previous one
+	steps := []struct {
+		want   string
+		remove func()
+	}{
+		{"from-stdin", func() { globals.passwordStdin = false }},
+		{"from-file", func() { globals.passwordFile = "" }},
+		{"from-env", func() { t.Setenv("TEST_BBOX_PWD", "") }},
+		{"from-command", func() { profile.PasswordCommand = "" }},
+		{"from-keyring", func() { profile.Name = "work" }},
+	}
+	for _, step := range steps {
+		got, err := resolvePassword(profile)
+		if err != nil || got != step.want {
+			t.Fatalf("resolvePassword = %q, %v; want %q", got, err, step.want)
+		}
+		step.remove()
+	}
+
+	_, err = resolvePassword(profile)
+	if err == nil || !strings.Contains(err.Error(), `no password for profile "work"`) {
+		t.Errorf("resolvePassword without any source err = %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	bboxclient "bbox-cli/client"
+)
+
+// sessionPath returns the cache file holding the session of a profile
+func sessionPath(profile string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bboxcli", profile+".session.json"), nil
+}
+
+// loadSession reads the cached session of the profile. Sessions saved for
+// another router URL are ignored.
+func loadSession(profile Profile) (bboxclient.Session, bool) {
+	var session bboxclient.Session
+
+	path, err := sessionPath(profile.Name)
+	if err != nil {
+		return session, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return session, false
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return session, false
+	}
+	return session, session.URL == profile.URL
+}
+
+// saveSession writes the session to the profile cache file, readable by the
+// current user only
+func saveSession(profile Profile, session bboxclient.Session) error {
+	path, err := sessionPath(profile.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a concurrent invocation never reads
+	// a partial session
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// deleteSession removes the cached session of the profile, if any
+func deleteSession(profile Profile) error {
+	path, err := sessionPath(profile.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// authenticate reuses the cached session of the profile when it is still
+// usable and logs in otherwise
+func authenticate(client *bboxclient.BboxClient, profile Profile, password string) error {
+	auth := client.Auth()
+
+	if session, ok := loadSession(profile); ok {
+		auth.Resume(session, password)
+		if client.Bearer().Valid() {
+			return nil
+		}
+		// The session cookie may outlive the token: try a plain refresh
+		// before logging in again
+		if err := auth.ObtainBearerToken(); err == nil && client.Bearer().Valid() {
+			return nil
+		}
+	}
+
+	return auth.BasicAuth(password)
+}
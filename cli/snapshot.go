@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	bboxclient "bbox-cli/client"
+
+	"gopkg.in/yaml.v3"
+)
+
+// handleExport implements "bboxcli export"
+func handleExport(conn *connection, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	what := flags.String("what", "firewall,nat", "Comma-separated sections to export: firewall, nat")
+	output := flags.String("o", "", "Snapshot file, .yaml or .yml for YAML (default JSON on stdout)")
+	flags.Parse(args)
+
+	var sections []string
+	for _, section := range strings.Split(*what, ",") {
+		if section = strings.TrimSpace(section); section != "" {
+			sections = append(sections, section)
+		}
+	}
+
+	snapshot, err := conn.Client().TakeSnapshot(sections...)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	data, err := encodeSnapshot(snapshot, *output)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0o600); err != nil {
+		fatalf("Error writing %s: %v", *output, err)
+	}
+	fmt.Printf("Saved %d firewall and %d NAT rules from %s (firmware %s) to %s\n",
+		len(snapshot.Firewall), len(snapshot.Nat), snapshot.Meta.Model, snapshot.Meta.Firmware, *output)
+}
+
+// handleRestore implements "bboxcli restore <file> [--dry-run] [--yes]"
+func handleRestore(conn *connection, args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Only print what would be restored")
+	yes := flags.Bool("yes", false, "Restore without asking for confirmation")
+	files := parseInterspersed(flags, args)
+	if len(files) != 1 {
+		fmt.Println("Error: restore requires a snapshot file")
+		exit(1)
+	}
+	path := files[0]
+
+	snapshot, err := loadSnapshot(path)
+	if err != nil {
+		fatalf("Error reading %s: %v", path, err)
+	}
+	for _, rule := range snapshot.Firewall {
+		exitOnInvalidRule(rule.WithDefaults())
+	}
+	for _, rule := range snapshot.Nat {
+		exitOnValidationError(fmt.Sprintf("NAT rule %q", rule.Description), rule.Validate())
+	}
+
+	client := conn.Client()
+	if device, err := client.GetDeviceInfo(); err == nil && device.ModelName != snapshot.Meta.Model {
+		fmt.Printf("Note: snapshot was taken on %s, restoring to %s\n", snapshot.Meta.Model, device.ModelName)
+	}
+
+	plan, err := client.PlanRestore(snapshot)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+
+	printRestorePlan(plan)
+	if *dryRun || plan.Empty() {
+		return
+	}
+	if !*yes {
+		if !isInteractive() {
+			fmt.Println("Error: restore changes the router; pass --yes to confirm without a terminal")
+			exit(1)
+		}
+		if !confirm("Restore the snapshot?") {
+			fmt.Println("Aborted, router left unchanged")
+			return
+		}
+	}
+
+	mapping, err := client.ApplyRestorePlan(plan)
+	printIDMapping(mapping)
+	if err != nil {
+		fatalf("Error restoring snapshot: %v", err)
+	}
+	fmt.Println("Snapshot restored successfully")
+}
+
+// encodeSnapshot writes YAML for .yaml and .yml files and JSON otherwise
+func encodeSnapshot(snapshot bboxclient.Snapshot, path string) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Marshal(snapshot)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	return append(data, '\n'), err
+}
+
+func loadSnapshot(path string) (bboxclient.Snapshot, error) {
+	var snapshot bboxclient.Snapshot
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &snapshot)
+	default:
+		err = json.Unmarshal(data, &snapshot)
+	}
+	if err != nil {
+		return snapshot, err
+	}
+
+	if snapshot.Meta.Version > bboxclient.SnapshotVersion {
+		return snapshot, fmt.Errorf("snapshot format %d is newer than this bboxcli supports (%d)",
+			snapshot.Meta.Version, bboxclient.SnapshotVersion)
+	}
+	if len(snapshot.Meta.Sections) == 0 {
+		return snapshot, fmt.Errorf("snapshot contains no sections")
+	}
+	return snapshot, nil
+}
+
+func printRestorePlan(plan bboxclient.RestorePlan) {
+	if plan.Empty() {
+		fmt.Println("No changes. The router already matches the snapshot.")
+		return
+	}
+
+	for _, step := range plan.Steps {
+		name := fmt.Sprintf("%s rule %q", step.Section, step.Description)
+		switch step.Action {
+		case bboxclient.PlanCreate:
+			fmt.Printf("+ create %s (snapshot ID %d)\n", name, step.SourceID)
+		case bboxclient.PlanUpdate:
+			fmt.Printf("~ update %s (ID %d)\n", name, step.TargetID)
+			for _, change := range step.Changes {
+				fmt.Printf("    %s\n", change)
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Restore: %d to create, %d to update, %d unchanged\n",
+		plan.Count(bboxclient.PlanCreate),
+		plan.Count(bboxclient.PlanUpdate),
+		plan.Count(bboxclient.RestoreUnchanged),
+	)
+}
+
+func printIDMapping(mapping bboxclient.IDMapping) {
+	for _, section := range []string{bboxclient.SnapshotFirewall, bboxclient.SnapshotNat} {
+		for _, pair := range sortedPairs(mapping[section]) {
+			if pair[0] != pair[1] {
+				fmt.Printf("%s rule %d is now ID %d\n", section, pair[0], pair[1])
+			}
+		}
+	}
+}
+
+// sortedPairs returns the entries of m sorted by key
+func sortedPairs(m map[int]int) [][2]int {
+	var pairs [][2]int
+	for k, v := range m {
+		pairs = append(pairs, [2]int{k, v})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
@@ -1,8 +1,16 @@
 package cli
 
 import (
-	bboxclient "bbox-cli/client"
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"strings"
+
+	bboxclient "bbox-cli/client"
+
+	"golang.org/x/term"
 )
 
 func truncate(s string, maxLen int) string {
@@ -27,15 +35,89 @@ func repeatString(s string, count int) string {
 	return result
 }
 
+// stdin is shared by every prompt so that buffered input is not lost
+// between calls
+var stdin = bufio.NewReader(os.Stdin)
+
+// readInput prints the prompt and returns the next line of input, which may
+// contain spaces
 func readInput(prompt string) string {
 	fmt.Print(prompt)
-	var input string
-	fmt.Scanln(&input)
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// isInteractive reports whether stdin is a terminal
+func isInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// missingFlags returns a function reporting whether the value of a flag that
+// was not set should be prompted for, which is only done on a terminal
+func missingFlags(set map[string]bool) func(name string) bool {
+	interactive := isInteractive()
+	return func(name string) bool {
+		return interactive && !set[name]
+	}
+}
+
+// readInputDefault prompts with the current value shown in brackets and
+// returns it unchanged when the user just presses Enter.
+func readInputDefault(prompt, current string) string {
+	input := readInput(fmt.Sprintf("%s [%s]: ", prompt, current))
+	if input == "" {
+		return current
+	}
 	return input
 }
 
-func parseIPOrPort(input string) (bboxclient.StringOrInt, bboxclient.EnableState) {
+// readMatchDefault prompts for an address or port field showing its current
+// value, "any" when empty. Enter keeps the value, "any" clears it and a
+// leading ! negates the new value.
+func readMatchDefault(prompt string, current bboxclient.StringOrInt, not bboxclient.EnableState) (bboxclient.StringOrInt, bboxclient.EnableState) {
+	shown := negated(defaultIfEmpty(current.String(), "any"), not == bboxclient.Enabled)
+	input := readInput(fmt.Sprintf("%s [%s]: ", prompt, shown))
 	if input == "" {
+		return current, not
+	}
+	return parseIPOrPort(input)
+}
+
+// readAnyDefault prompts for a field that may be ANY, showing "any" when it
+// is empty. Enter keeps the value and "any" clears it.
+func readAnyDefault(prompt string, current bboxclient.StringOrInt) bboxclient.StringOrInt {
+	input := readInput(fmt.Sprintf("%s [%s]: ", prompt, defaultIfEmpty(current.String(), "any")))
+	if input == "" {
+		return current
+	}
+	return parseAny(input)
+}
+
+// parseInterspersed parses flags that may come before, between or after the
+// positional arguments, which it returns
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// confirm asks a yes/no question, defaulting to no
+func confirm(prompt string) bool {
+	answer := strings.ToLower(readInput(prompt + " (y/N): "))
+	return answer == "y" || answer == "yes"
+}
+
+// parseIPOrPort reads an address or port field. Empty input and the "any"
+// keyword mean ANY; a leading ! negates the value.
+func parseIPOrPort(input string) (bboxclient.StringOrInt, bboxclient.EnableState) {
+	if input == "" || strings.EqualFold(input, "any") {
 		return bboxclient.StringOrInt(""), bboxclient.Disabled
 	}
 	if input[0] == '!' {
@@ -44,16 +126,108 @@ func parseIPOrPort(input string) (bboxclient.StringOrInt, bboxclient.EnableState
 	return bboxclient.StringOrInt(input), bboxclient.Disabled
 }
 
+// parseAny reads a field where empty input and the "any" keyword mean ANY
+func parseAny(input string) bboxclient.StringOrInt {
+	if strings.EqualFold(input, "any") {
+		return ""
+	}
+	return bboxclient.StringOrInt(input)
+}
+
 func parseProtocols(input string) bboxclient.Protocol {
-	if input == "" {
+	if input == "" || strings.EqualFold(input, "any") {
 		return bboxclient.ProtocolAny
 	}
 	return bboxclient.Protocol(input)
 }
 
+// parseAction accepts the actions in any case, e.g. "accept" or "DROP"
+func parseAction(input string) bboxclient.Action {
+	switch {
+	case strings.EqualFold(input, string(bboxclient.ActionAllow)):
+		return bboxclient.ActionAllow
+	case strings.EqualFold(input, string(bboxclient.ActionDeny)):
+		return bboxclient.ActionDeny
+	}
+	return bboxclient.Action(input)
+}
+
+// parseIPVersion accepts the short forms v4, v6 and both as well as the
+// values used by the API
+func parseIPVersion(input string) bboxclient.IPProtocol {
+	switch strings.ToLower(input) {
+	case "4", "v4", "ipv4":
+		return bboxclient.IPProtocolIPv4
+	case "6", "v6", "ipv6":
+		return bboxclient.IPProtocolIPv6
+	case "both", "all", "4+6", "v4+v6", "ipv4+ipv6":
+		return bboxclient.IPProtocolBoth
+	}
+	return bboxclient.IPProtocol(input)
+}
+
 func parseEnable(input string) bboxclient.EnableState {
 	if input == "y" || input == "Y" {
 		return bboxclient.Enabled
 	}
 	return bboxclient.Disabled
 }
+
+// parseOnOff accepts on/off and the usual synonyms
+func parseOnOff(input string) (bboxclient.EnableState, bool) {
+	switch strings.ToLower(input) {
+	case "on", "1", "true", "yes", "enable":
+		return bboxclient.Enabled, true
+	case "off", "0", "false", "no", "disable":
+		return bboxclient.Disabled, true
+	}
+	return bboxclient.Disabled, false
+}
+
+// enableFlags reads the --enable and --disable flags of a rule command and
+// reports whether either was given. --enable=false disables and
+// --disable=false enables; giving both is an error.
+func enableFlags(flags *flag.FlagSet, enable, disable bool) (bboxclient.EnableState, bool) {
+	var enableSet, disableSet bool
+	flags.Visit(func(f *flag.Flag) {
+		enableSet = enableSet || f.Name == "enable"
+		disableSet = disableSet || f.Name == "disable"
+	})
+
+	switch {
+	case enableSet && disableSet:
+		fmt.Println("Error: --enable and --disable cannot be used together")
+		exit(1)
+	case enableSet && enable, disableSet && !disable:
+		return bboxclient.Enabled, true
+	case enableSet, disableSet:
+		return bboxclient.Disabled, true
+	}
+	return bboxclient.Disabled, false
+}
+
+// exitOnInvalidRule prints the field errors of rule and exits when it does
+// not validate
+func exitOnInvalidRule(rule bboxclient.FirewallRule) {
+	base, _ := bboxclient.BaseDescription(rule.Description)
+	exitOnValidationError(fmt.Sprintf("firewall rule %q", base), rule.Validate())
+}
+
+// exitOnValidationError prints the field errors of err, if any, and exits
+func exitOnValidationError(what string, err error) {
+	if err == nil {
+		return
+	}
+
+	var errs bboxclient.ValidationErrors
+	if !errors.As(err, &errs) {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	fmt.Printf("Error: invalid %s\n", what)
+	for _, e := range errs {
+		fmt.Printf("  %s\n", e)
+	}
+	exit(1)
+}
@@ -1,10 +1,13 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
-	"time"
 )
 
 type AuthInterface struct {
@@ -16,49 +19,120 @@ type DeviceTokenResponse struct {
 }
 
 func (ai *AuthInterface) ObtainBearerToken() error {
-	resp, err := ai.Client.Get("/device/token")
+	return ai.ObtainBearerTokenContext(context.Background())
+}
+
+// ObtainBearerTokenContext is like ObtainBearerToken but bound to ctx
+func (ai *AuthInterface) ObtainBearerTokenContext(ctx context.Context) error {
+	token, err := ai.fetchToken(ctx)
 	if err != nil {
 		return err
 	}
+	ai.Client.setBearer(token)
+	return nil
+}
+
+// fetchToken requests a new device token without storing it
+func (ai *AuthInterface) fetchToken(ctx context.Context) (*DeviceToken, error) {
+	req, err := ai.Client.NewRequestContext(ctx, "GET", "/device/token", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ai.Client.send(req)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
+	if err := ai.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
 	// La réponse est un array
 	var responses []DeviceTokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(responses) == 0 {
-		return errors.New("no device token in response")
+		return nil, errors.New("no device token in response")
 	}
+	return &responses[0].Device, nil
+}
 
-	ai.Client.Bearer = &responses[0].Device
-	return nil
+func (ai *AuthInterface) BasicAuth(password string) error {
+	return ai.BasicAuthContext(context.Background(), password)
 }
 
-func (ai *AuthInterface) StartTokenRefresher() error {
-	if ai.Client.Bearer == nil {
-		return errors.New("can't start before BasicAuth")
+// BasicAuthContext is like BasicAuth but bound to ctx. When the router locks
+// the login after repeated failures the error is a *LockoutError telling how
+// long to wait.
+func (ai *AuthInterface) BasicAuthContext(ctx context.Context, password string) error {
+	form := url.Values{"password": {password}}
+	req, err := ai.Client.NewRequestContext(ctx, "POST", "/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	go func() {
-		for {
-			expiryTime, _ := time.Parse("2006-01-02T00:00:00+0100", ai.Client.Bearer.Expires)
-			<-time.After(time.Until(expiryTime) - 1*time.Minute)
-			ai.ObtainBearerToken()
+	resp, err := ai.Client.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := ai.Client.checkResponse(resp, http.StatusOK); err != nil {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			err = newLockoutError(resp, err.(*APIError))
 		}
-	}()
+		return fmt.Errorf("login failed: %w", err)
+	}
+	if len(ai.Client.GetCookies()) == 0 {
+		return errors.New("login failed: the router set no session cookie")
+	}
 
+	if err := ai.ObtainBearerTokenContext(ctx); err != nil {
+		return fmt.Errorf("failed to obtain device token after login: %w", err)
+	}
+	ai.Client.password = password
 	return nil
 }
 
-func (ai *AuthInterface) BasicAuth(password string) error {
-	_, err := ai.Client.Post(
-		"/login", "application/x-www-form-urlencoded", strings.NewReader("password="+password),
-	)
+// Logout ends the session on the router and forgets the cookies, token and
+// password of the client. A session the router already dropped is not an
+// error.
+func (ai *AuthInterface) Logout() error {
+	return ai.LogoutContext(context.Background())
+}
+
+// LogoutContext is like Logout but bound to ctx
+func (ai *AuthInterface) LogoutContext(ctx context.Context) error {
+	bc := ai.Client
+	ai.Stop()
+
+	req, err := bc.NewRequestContext(ctx, "POST", "/logout", nil)
 	if err != nil {
 		return err
 	}
-	ai.ObtainBearerToken()
+	resp, err := bc.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		if err := bc.checkResponse(resp, http.StatusOK); err != nil {
+			return fmt.Errorf("logout failed: %w", err)
+		}
+	}
+
+	var expired []*http.Cookie
+	for _, c := range bc.GetCookies() {
+		expired = append(expired, &http.Cookie{Name: c.Name, Path: "/", MaxAge: -1})
+	}
+	bc.Client.Jar.SetCookies(bc.Url, expired)
+	bc.setBearer(nil)
+	bc.password = ""
 	return nil
 }
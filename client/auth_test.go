@@ -0,0 +1,286 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bboxclient "bbox-cli/client"
+	"bbox-cli/client/bboxtest"
+)
+
+func TestBasicAuth(t *testing.T) {
+	_, client := newTestClient(t)
+
+	if client.Bearer() == nil || client.Bearer().Token == "" {
+		t.Fatal("BasicAuth did not obtain a bearer token")
+	}
+	if client.Bearer().Expires == "" {
+		t.Error("bearer token has no expiry")
+	}
+}
+
+func TestBasicAuthWrongPassword(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	client, _ := server.NewClient()
+	err := client.Auth().BasicAuth("wrong")
+	if !bboxclient.IsUnauthorized(err) {
+		t.Errorf("err = %v, want a 401 APIError", err)
+	}
+	if client.Bearer() != nil {
+		t.Error("bearer token obtained with a wrong password")
+	}
+}
+
+func TestObtainBearerTokenRefreshes(t *testing.T) {
+	_, client := newTestClient(t)
+
+	first := client.Bearer().Token
+	if err := client.Auth().ObtainBearerToken(); err != nil {
+		t.Fatalf("ObtainBearerToken: %v", err)
+	}
+	if client.Bearer().Token == first {
+		t.Error("ObtainBearerToken returned the same token twice")
+	}
+}
+
+func TestStartTokenRefresherRequiresAuth(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	client, _ := server.NewClient()
+	if err := client.Auth().StartTokenRefresher(); err == nil {
+		t.Error("StartTokenRefresher succeeded without a bearer token")
+	}
+}
+
+func TestWriteCallRefreshesExpiringToken(t *testing.T) {
+	server, client := newTestClient(t)
+	session := client.Session()
+	session.Bearer.Expires = time.Now().Add(30 * time.Second).Format(time.RFC3339)
+	client.Auth().Resume(session, "")
+
+	if err := client.Firewall().AddFirewallRule(bboxclient.FirewallRule{Description: "x"}); err != nil {
+		t.Fatalf("AddFirewallRule: %v", err)
+	}
+	if n := countRequests(server.Requests(), "GET /device/token"); n != 2 {
+		t.Errorf("obtained %d tokens, want 2", n)
+	}
+	if !client.Bearer().Valid() {
+		t.Error("token not renewed")
+	}
+}
+
+func TestConcurrentWriteCallsShareRefresh(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	// Slow token requests down so that the writes overlap, and read the
+	// token from a hook while a refresh is in flight
+	var client *bboxclient.BboxClient
+	slowToken := func(next http.RoundTripper) http.RoundTripper {
+		return bboxclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/device/token") {
+				time.Sleep(100 * time.Millisecond)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+	readToken := func(req *http.Request) error {
+		if strings.HasSuffix(req.URL.Path, "/device/token") {
+			client.Bearer()
+		}
+		return nil
+	}
+	client, err := bboxclient.NewClient(server.BaseURL(),
+		bboxclient.WithMiddleware(slowToken), bboxclient.WithBeforeRequest(readToken))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+	session := client.Session()
+	session.Bearer.Expires = time.Now().Add(30 * time.Second).Format(time.RFC3339)
+	client.Auth().Resume(session, "")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- client.Firewall().AddFirewallRule(bboxclient.FirewallRule{Description: fmt.Sprint("rule", i)})
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes blocked while the token was refreshed")
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("AddFirewallRule: %v", err)
+		}
+	}
+	if n := countRequests(server.Requests(), "GET /device/token"); n != 2 {
+		t.Errorf("obtained %d tokens, want 2", n)
+	}
+}
+
+func TestWriteCallSurfacesRefreshFailure(t *testing.T) {
+	server, client := newTestClient(t)
+	session := client.Session()
+	session.Bearer.Expires = time.Now().Format(time.RFC3339)
+	client.Auth().Resume(session, "")
+	server.ExpireSessions()
+
+	err := client.Firewall().DeleteFirewallRule("1")
+	if err == nil || !strings.Contains(err.Error(), "failed to refresh device token") {
+		t.Errorf("err = %v, want a refresh failure", err)
+	}
+	if !bboxclient.IsUnauthorized(err) {
+		t.Errorf("refresh error does not wrap the 401: %v", err)
+	}
+}
+
+func TestTokenRefresher(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+	// Expire one second after entering the refresh margin
+	server.TokenTTL = time.Minute + time.Second
+
+	client, _ := server.NewClient()
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+	first := client.Bearer().Token
+	if err := client.Auth().StartTokenRefresher(); err != nil {
+		t.Fatalf("StartTokenRefresher: %v", err)
+	}
+	if err := client.Auth().StartTokenRefresher(); err == nil {
+		t.Error("started a second refresher")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Bearer().Token == first && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if client.Bearer().Token == first {
+		t.Error("token not refreshed in the background")
+	}
+	if err := client.Auth().Stop(); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+	if err := client.Auth().Stop(); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+}
+
+func TestTokenRefresherStopsWithContext(t *testing.T) {
+	_, client := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := client.Auth().StartTokenRefresherContext(ctx); err != nil {
+		t.Fatalf("StartTokenRefresherContext: %v", err)
+	}
+	cancel()
+
+	done := make(chan error)
+	go func() { done <- client.Auth().Stop() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Stop: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("refresher still running after cancellation")
+	}
+}
+
+func TestTokenRefresherReportsUnreadableExpiry(t *testing.T) {
+	server, logged := newTestClient(t)
+	session := logged.Session()
+	session.Bearer.Expires = "tomorrow"
+
+	client, _ := server.NewClient()
+	client.Auth().Resume(session, testPassword)
+	if err := client.Auth().StartTokenRefresher(); err != nil {
+		t.Fatalf("StartTokenRefresher: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := client.Auth().Stop(); err == nil {
+		t.Error("Stop did not report the unreadable expiry")
+	}
+}
+
+func TestBasicAuthEncodesPassword(t *testing.T) {
+	const password = "a&b=c+d %e"
+	server := bboxtest.NewServer(password)
+	defer server.Close()
+
+	client, _ := server.NewClient()
+	if err := client.Auth().BasicAuth(password); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+}
+
+func TestBasicAuthLockout(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	client, _ := server.NewClient()
+	for i := 0; i < server.LoginAttempts; i++ {
+		if err := client.Auth().BasicAuth("wrong"); !bboxclient.IsUnauthorized(err) {
+			t.Fatalf("attempt %d: err = %v, want a 401", i+1, err)
+		}
+	}
+
+	err := client.Auth().BasicAuth(testPassword)
+	var lockout *bboxclient.LockoutError
+	if !errors.As(err, &lockout) {
+		t.Fatalf("err = %v, want a *LockoutError", err)
+	}
+	if lockout.Wait < 59*time.Second || lockout.Wait > time.Minute {
+		t.Errorf("Wait = %s, want about 1m", lockout.Wait)
+	}
+	if !bboxclient.IsRateLimited(err) {
+		t.Error("IsRateLimited = false")
+	}
+	if !strings.Contains(err.Error(), "retry in 1m0s") {
+		t.Errorf("err = %q", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	server, client := newTestClient(t)
+	session := client.Session()
+
+	if err := client.Auth().Logout(); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if client.Bearer() != nil || len(client.GetCookies()) != 0 {
+		t.Error("client still holds a session")
+	}
+
+	// The router must have dropped the session too
+	other, _ := server.NewClient()
+	other.Auth().Resume(session, "")
+	if _, err := other.Firewall().GetFirewallRules(); !bboxclient.IsUnauthorized(err) {
+		t.Errorf("err = %v, want a 401 with the logged out session", err)
+	}
+	if err := other.Auth().Logout(); err != nil {
+		t.Errorf("Logout of an expired session: %v", err)
+	}
+}
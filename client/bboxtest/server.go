@@ -0,0 +1,793 @@
+// Package bboxtest provides an in-memory fake of the Bbox web API for tests
+// and offline development.
+//
+// The fake mimics the behaviour the client package relies on: responses are
+// wrapped in a JSON array, /login sets a session cookie, write calls require
+// the btoken obtained from /device/token, and creations answer 201 while
+// updates and deletions answer 200.
+package bboxtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bboxclient "bbox-cli/client"
+)
+
+// APIPrefix is the path under which the fake serves the API, matching the
+// real router
+const APIPrefix = "/api/v1"
+
+// SessionCookie is the name of the cookie set by /login
+const SessionCookie = "BBOX_ID"
+
+// Server is a fake Bbox API running on an httptest.Server
+type Server struct {
+	*httptest.Server
+
+	// Password accepted by /login
+	Password string
+
+	// TokenTTL is the lifetime of the device tokens handed out by
+	// /device/token
+	TokenTTL time.Duration
+
+	// Latency delays every response, to simulate a busy router
+	Latency time.Duration
+
+	// LoginAttempts is the number of wrong passwords /login accepts before
+	// refusing logins for LockoutDuration, 0 for no limit
+	LoginAttempts   int
+	LockoutDuration time.Duration
+
+	// Device is returned by /device
+	Device bboxclient.DeviceInfo
+
+	mu             sync.Mutex
+	sessions       map[string]bool
+	tokens         map[string]time.Time
+	firewall       []bboxclient.FirewallRule
+	nat            []bboxclient.NatRule
+	pinholes       []bboxclient.PinholeRule
+	settings       bboxclient.FirewallSettings
+	nextFirewallID int
+	nextNatID      int
+	nextPinholeID  int
+	requests       []string
+	failedLogins   int
+	lockedUntil    time.Time
+}
+
+// NewServer starts a fake Bbox accepting the given password. The caller must
+// call Close when done.
+func NewServer(password string) *Server {
+	s := &Server{
+		Password:        password,
+		TokenTTL:        time.Hour,
+		LoginAttempts:   3,
+		LockoutDuration: time.Minute,
+		sessions:        make(map[string]bool),
+		tokens:          make(map[string]time.Time),
+		nextFirewallID:  1,
+		nextNatID:       1,
+		nextPinholeID:   1,
+		Device: bboxclient.DeviceInfo{
+			ModelName:    "Bbox fake",
+			SerialNumber: "0000000000",
+			Main:         bboxclient.FirmwareVersion{Version: "1.0.0", Date: "2024-01-01"},
+		},
+		settings: bboxclient.FirewallSettings{
+			Enable:        bboxclient.Enabled,
+			Level:         bboxclient.FirewallLevelMedium,
+			PingResponder: bboxclient.Enabled,
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIPrefix+"/login", s.handleLogin)
+	mux.HandleFunc(APIPrefix+"/logout", s.handleLogout)
+	mux.HandleFunc(APIPrefix+"/device/token", s.handleToken)
+	mux.HandleFunc(APIPrefix+"/device", s.handleDevice)
+	mux.HandleFunc(APIPrefix+"/firewall", s.handleFirewallSettings)
+	mux.HandleFunc(APIPrefix+"/firewall/pingresponder", s.handleFirewallSwitch(&s.settings.PingResponder))
+	mux.HandleFunc(APIPrefix+"/firewall/gamermode", s.handleFirewallSwitch(&s.settings.GamerMode))
+	mux.HandleFunc(APIPrefix+"/firewall/rules", s.handleFirewallRules)
+	mux.HandleFunc(APIPrefix+"/firewall/rules/", s.handleFirewallRule)
+	mux.HandleFunc(APIPrefix+"/firewall/pinhole", s.handlePinholes)
+	mux.HandleFunc(APIPrefix+"/firewall/pinhole/", s.handlePinhole)
+	mux.HandleFunc(APIPrefix+"/nat/rules", s.handleNatRules)
+	mux.HandleFunc(APIPrefix+"/nat/rules/", s.handleNatRule)
+
+	s.Server = httptest.NewServer(s.record(mux))
+	return s
+}
+
+// BaseURL returns the API root to pass to client.NewClient
+func (s *Server) BaseURL() *url.URL {
+	u, _ := url.Parse(s.URL + APIPrefix)
+	return u
+}
+
+// NewClient returns an unauthenticated client pointing at the fake
+func (s *Server) NewClient() (*bboxclient.BboxClient, error) {
+	return bboxclient.NewClient(s.BaseURL())
+}
+
+// Requests returns the "METHOD /path" of every request received so far
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// FirewallRules returns a copy of the firewall rules currently stored
+func (s *Server) FirewallRules() []bboxclient.FirewallRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]bboxclient.FirewallRule(nil), s.firewall...)
+}
+
+// SetFirewallRules replaces the stored firewall rules. Rules without an ID
+// get one assigned.
+func (s *Server) SetFirewallRules(rules []bboxclient.FirewallRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.firewall = nil
+	for _, rule := range rules {
+		if rule.ID == 0 {
+			rule.ID = s.nextFirewallID
+		}
+		if rule.ID >= s.nextFirewallID {
+			s.nextFirewallID = rule.ID + 1
+		}
+		s.firewall = append(s.firewall, rule)
+	}
+}
+
+// NatRules returns a copy of the NAT rules currently stored
+func (s *Server) NatRules() []bboxclient.NatRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]bboxclient.NatRule(nil), s.nat...)
+}
+
+// SetNatRules replaces the stored NAT rules. Rules without an ID get one
+// assigned.
+func (s *Server) SetNatRules(rules []bboxclient.NatRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nat = nil
+	for _, rule := range rules {
+		if rule.ID == 0 {
+			rule.ID = s.nextNatID
+		}
+		if rule.ID >= s.nextNatID {
+			s.nextNatID = rule.ID + 1
+		}
+		s.nat = append(s.nat, rule)
+	}
+}
+
+// FirewallSettings returns the global firewall settings currently stored
+func (s *Server) FirewallSettings() bboxclient.FirewallSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+// SetFirewallSettings replaces the global firewall settings
+func (s *Server) SetFirewallSettings(settings bboxclient.FirewallSettings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = settings
+}
+
+// PinholeRules returns a copy of the IPv6 pinholes currently stored
+func (s *Server) PinholeRules() []bboxclient.PinholeRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]bboxclient.PinholeRule(nil), s.pinholes...)
+}
+
+// SetPinholeRules replaces the stored IPv6 pinholes. Pinholes without an ID
+// get one assigned.
+func (s *Server) SetPinholeRules(rules []bboxclient.PinholeRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pinholes = nil
+	for _, rule := range rules {
+		if rule.ID == 0 {
+			rule.ID = s.nextPinholeID
+		}
+		if rule.ID >= s.nextPinholeID {
+			s.nextPinholeID = rule.ID + 1
+		}
+		s.pinholes = append(s.pinholes, rule)
+	}
+}
+
+// ExpireSessions forgets every session cookie and device token, as the
+// router does when a session times out
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]bool)
+	s.tokens = make(map[string]time.Time)
+}
+
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, APIPrefix))
+		latency := s.Latency
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if wait := time.Until(s.lockedUntil); wait > 0 {
+		seconds := int(wait.Round(time.Second) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		writeError(w, r, http.StatusTooManyRequests, "login", "Too many attempts")
+		return
+	}
+
+	form, err := readForm(r)
+	if err != nil || form.Get("password") != s.Password {
+		s.failedLogins++
+		if s.LoginAttempts > 0 && s.failedLogins >= s.LoginAttempts {
+			s.failedLogins = 0
+			s.lockedUntil = time.Now().Add(s.LockoutDuration)
+		}
+		writeError(w, r, http.StatusUnauthorized, "password", "Invalid")
+		return
+	}
+
+	id := randomHex()
+	s.failedLogins = 0
+	s.sessions[id] = true
+
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: id, Path: "/"})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+		return
+	}
+	if !s.authenticated(w, r) {
+		return
+	}
+
+	c, _ := r.Cookie(SessionCookie)
+	s.mu.Lock()
+	delete(s.sessions, c.Value)
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) {
+		return
+	}
+
+	token := randomHex()
+	expires := time.Now().Add(s.TokenTTL)
+	s.mu.Lock()
+	s.tokens[token] = expires
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, []bboxclient.DeviceTokenResponse{{
+		Device: bboxclient.DeviceToken{Token: token, Expires: expires.Format(time.RFC3339)},
+	}})
+}
+
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) {
+		return
+	}
+	s.mu.Lock()
+	device := s.Device
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, []bboxclient.DeviceResponse{{Device: device}})
+}
+
+func (s *Server) handleFirewallSettings(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		settings := s.settings
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, []bboxclient.FirewallSettingsResponse{{Firewall: settings}})
+	case http.MethodPut:
+		if !s.validToken(w, r) {
+			return
+		}
+		form, err := readForm(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "body", "Invalid")
+			return
+		}
+
+		level := bboxclient.FirewallLevel(form.Get("level"))
+		switch level {
+		case bboxclient.FirewallLevelLow, bboxclient.FirewallLevelMedium,
+			bboxclient.FirewallLevelHigh, bboxclient.FirewallLevelCustom:
+		default:
+			writeError(w, r, http.StatusBadRequest, "level", "Invalid")
+			return
+		}
+
+		s.mu.Lock()
+		s.settings.Level = level
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+	}
+}
+
+// handleFirewallSwitch serves the endpoints turning one global switch on or
+// off with an "enable" form value. field points into s.settings and is only
+// written with s.mu held.
+func (s *Server) handleFirewallSwitch(field *bboxclient.EnableState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authenticated(w, r) {
+			return
+		}
+		if r.Method != http.MethodPut {
+			writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+			return
+		}
+		if !s.validToken(w, r) {
+			return
+		}
+		form, err := readForm(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "body", "Invalid")
+			return
+		}
+
+		v, err := strconv.Atoi(form.Get("enable"))
+		if err != nil || (v != 0 && v != 1) {
+			writeError(w, r, http.StatusBadRequest, "enable", "Invalid")
+			return
+		}
+
+		s.mu.Lock()
+		*field = bboxclient.EnableState(v)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) handleFirewallRules(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		rules := append([]bboxclient.FirewallRule{}, s.firewall...)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, []bboxclient.FirewallResponse{{
+			Firewall: bboxclient.Firewall{Rules: rules},
+		}})
+	case http.MethodPost:
+		if !s.validToken(w, r) {
+			return
+		}
+		form, err := readForm(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "body", "Invalid")
+			return
+		}
+
+		s.mu.Lock()
+		rule := bboxclient.FirewallRule{ID: s.nextFirewallID}
+		s.nextFirewallID++
+		applyFirewallForm(&rule, form)
+		s.firewall = append(s.firewall, rule)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+	}
+}
+
+func (s *Server) handleFirewallRule(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) || !s.validToken(w, r) {
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, APIPrefix+"/firewall/rules/"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "id", "Invalid")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := -1
+	for i := range s.firewall {
+		if s.firewall[i].ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		writeError(w, r, http.StatusNotFound, "id", "Not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		form, err := readForm(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "body", "Invalid")
+			return
+		}
+		applyFirewallForm(&s.firewall[index], form)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		s.firewall = append(s.firewall[:index], s.firewall[index+1:]...)
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+	}
+}
+
+func (s *Server) handlePinholes(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		rules := append([]bboxclient.PinholeRule{}, s.pinholes...)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, []bboxclient.PinholeResponse{{
+			Pinhole: bboxclient.Pinhole{Rules: rules},
+		}})
+	case http.MethodPost:
+		if !s.validToken(w, r) {
+			return
+		}
+		form, err := readForm(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "body", "Invalid")
+			return
+		}
+
+		s.mu.Lock()
+		rule := bboxclient.PinholeRule{ID: s.nextPinholeID}
+		s.nextPinholeID++
+		applyPinholeForm(&rule, form)
+		s.pinholes = append(s.pinholes, rule)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+	}
+}
+
+func (s *Server) handlePinhole(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) || !s.validToken(w, r) {
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, APIPrefix+"/firewall/pinhole/"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "id", "Invalid")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := -1
+	for i := range s.pinholes {
+		if s.pinholes[i].ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		writeError(w, r, http.StatusNotFound, "id", "Not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		form, err := readForm(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "body", "Invalid")
+			return
+		}
+		applyPinholeForm(&s.pinholes[index], form)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		s.pinholes = append(s.pinholes[:index], s.pinholes[index+1:]...)
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+	}
+}
+
+func (s *Server) handleNatRules(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		rules := append([]bboxclient.NatRule{}, s.nat...)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, []bboxclient.NatResponse{{
+			Nat: bboxclient.NatRules{Enable: bboxclient.Enabled, Rules: rules},
+		}})
+	case http.MethodPost:
+		if !s.validToken(w, r) {
+			return
+		}
+		form, err := readForm(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "body", "Invalid")
+			return
+		}
+
+		s.mu.Lock()
+		rule := bboxclient.NatRule{ID: s.nextNatID}
+		s.nextNatID++
+		applyNatForm(&rule, form)
+		s.nat = append(s.nat, rule)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+	}
+}
+
+func (s *Server) handleNatRule(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(w, r) || !s.validToken(w, r) {
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, APIPrefix+"/nat/rules/"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "id", "Invalid")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := -1
+	for i := range s.nat {
+		if s.nat[i].ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		writeError(w, r, http.StatusNotFound, "id", "Not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		form, err := readForm(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "body", "Invalid")
+			return
+		}
+		applyNatForm(&s.nat[index], form)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		s.nat = append(s.nat[:index], s.nat[index+1:]...)
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method", "Not allowed")
+	}
+}
+
+// authenticated checks the session cookie and answers 401 when it is missing
+func (s *Server) authenticated(w http.ResponseWriter, r *http.Request) bool {
+	c, err := r.Cookie(SessionCookie)
+	if err == nil {
+		s.mu.Lock()
+		ok := s.sessions[c.Value]
+		s.mu.Unlock()
+		if ok {
+			return true
+		}
+	}
+	writeError(w, r, http.StatusUnauthorized, "session", "Unauthorized")
+	return false
+}
+
+// validToken checks the btoken query parameter of write calls
+func (s *Server) validToken(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	expires, ok := s.tokens[r.URL.Query().Get("btoken")]
+	s.mu.Unlock()
+	if ok && time.Now().Before(expires) {
+		return true
+	}
+	writeError(w, r, http.StatusUnauthorized, "btoken", "Invalid")
+	return false
+}
+
+// readForm decodes a form-encoded body regardless of the Content-Type
+// header, as the router does
+func readForm(r *http.Request) (url.Values, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(body))
+}
+
+func applyFirewallForm(rule *bboxclient.FirewallRule, form url.Values) {
+	setString := func(key string, dst *bboxclient.StringOrInt) {
+		if form.Has(key) {
+			*dst = bboxclient.StringOrInt(form.Get(key))
+		}
+	}
+	setState := func(key string, dst *bboxclient.EnableState) {
+		if form.Has(key) {
+			v, _ := strconv.Atoi(form.Get(key))
+			*dst = bboxclient.EnableState(v)
+		}
+	}
+
+	if form.Has("description") {
+		rule.Description = form.Get("description")
+	}
+	if form.Has("action") {
+		rule.Action = bboxclient.Action(form.Get("action"))
+	}
+	if form.Has("order") {
+		rule.Order, _ = strconv.Atoi(form.Get("order"))
+	}
+	if form.Has("protocols") {
+		rule.Protocols = bboxclient.Protocol(form.Get("protocols"))
+	}
+	if form.Has("ipprotocol") {
+		rule.IPProtocol = bboxclient.IPProtocol(form.Get("ipprotocol"))
+	}
+	setState("enable", &rule.Enable)
+	setState("srcipnot", &rule.SrcIPNot)
+	setState("srcportnot", &rule.SrcPortNot)
+	setState("dstipnot", &rule.DstIPNot)
+	setState("dstportnot", &rule.DstPortNot)
+	setString("srcip", &rule.SrcIP)
+	setString("srcports", &rule.SrcPorts)
+	setString("dstip", &rule.DstIP)
+	setString("dstports", &rule.DstPorts)
+}
+
+func applyPinholeForm(rule *bboxclient.PinholeRule, form url.Values) {
+	setString := func(key string, dst *bboxclient.StringOrInt) {
+		if form.Has(key) {
+			*dst = bboxclient.StringOrInt(form.Get(key))
+		}
+	}
+	setState := func(key string, dst *bboxclient.EnableState) {
+		if form.Has(key) {
+			v, _ := strconv.Atoi(form.Get(key))
+			*dst = bboxclient.EnableState(v)
+		}
+	}
+
+	if form.Has("description") {
+		rule.Description = form.Get("description")
+	}
+	if form.Has("protocols") {
+		rule.Protocols = bboxclient.Protocol(form.Get("protocols"))
+	}
+	setState("enable", &rule.Enable)
+	setState("srcipnot", &rule.SrcIPNot)
+	setState("srcportnot", &rule.SrcPortNot)
+	setState("dstportnot", &rule.DstPortNot)
+	setString("srcip", &rule.SrcIP)
+	setString("srcports", &rule.SrcPorts)
+	setString("dstip", &rule.DstIP)
+	setString("dstports", &rule.DstPorts)
+}
+
+func applyNatForm(rule *bboxclient.NatRule, form url.Values) {
+	setString := func(key string, dst *bboxclient.StringOrInt) {
+		if form.Has(key) {
+			*dst = bboxclient.StringOrInt(form.Get(key))
+		}
+	}
+
+	if form.Has("enable") {
+		v, _ := strconv.Atoi(form.Get("enable"))
+		rule.Enable = bboxclient.EnableState(v)
+	}
+	if form.Has("description") {
+		rule.Description = form.Get("description")
+	}
+	if form.Has("protocol") {
+		rule.Protocol = bboxclient.Protocol(form.Get("protocol"))
+	}
+	setString("externalip", &rule.SrcIP)
+	setString("externalport", &rule.SrcPorts)
+	setString("internalip", &rule.TargetIP)
+	setString("internalport", &rule.TargetPorts)
+}
+
+// writeError answers with the error payload used by the router:
+//
+//	{"exception":{"domain":"v1/nat/rules","code":"401","errors":[{"name":"btoken","reason":"Invalid"}]}}
+func writeError(w http.ResponseWriter, r *http.Request, status int, name, reason string) {
+	type apiError struct {
+		Name   string `json:"name"`
+		Reason string `json:"reason"`
+	}
+	type exception struct {
+		Domain string     `json:"domain"`
+		Code   string     `json:"code"`
+		Errors []apiError `json:"errors"`
+	}
+
+	domain := strings.TrimPrefix(r.URL.Path, "/api/")
+	writeJSON(w, status, map[string]exception{
+		"exception": {
+			Domain: domain,
+			Code:   fmt.Sprint(status),
+			Errors: []apiError{{Name: name, Reason: reason}},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomHex() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
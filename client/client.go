@@ -1,6 +1,9 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/cookiejar"
@@ -15,20 +18,47 @@ type DeviceToken struct {
 type BboxClient struct {
 	Client *http.Client
 	Url    *url.URL
-	Bearer *DeviceToken
+
+	// tokens holds the device token, read with Bearer
+	tokens tokenManager
+
+	// password is remembered after a successful login so that an expired
+	// session can be renewed transparently
+	password string
+
+	// journal records rule changes when set by WithJournal
+	journal Journal
+
+	// Request pipeline set up by the options
+	transport  http.RoundTripper
+	tlsConfig  *tls.Config
+	middleware []Middleware
+	before     []BeforeHook
+	after      []AfterHook
+	retry      RetryPolicy
 }
 
-func NewClient(baseUrl *url.URL) (*BboxClient, error) {
+func NewClient(baseUrl *url.URL, opts ...Option) (*BboxClient, error) {
 	var client http.Client
 	myCookieJar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, err
 	}
 	client.Jar = myCookieJar
-	return &BboxClient{
+	client.Timeout = DefaultTimeout
+
+	bc := &BboxClient{
 		Client: &client,
 		Url:    baseUrl,
-	}, nil
+		retry:  DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(bc)
+	}
+	if client.Transport, err = bc.buildTransport(); err != nil {
+		return nil, err
+	}
+	return bc, nil
 }
 
 func (bc *BboxClient) GetCookies() []*http.Cookie {
@@ -36,20 +66,136 @@ func (bc *BboxClient) GetCookies() []*http.Cookie {
 }
 
 func (bc *BboxClient) NewRequest(method, path string, body io.Reader) (*http.Request, error) {
+	return bc.NewRequestContext(context.Background(), method, path, body)
+}
+
+// NewRequestContext builds a request for path, relative to the API root,
+// bound to ctx
+func (bc *BboxClient) NewRequestContext(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
 	u := bc.Url.JoinPath(path)
-	return http.NewRequest(method, u.String(), body)
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
 }
 
+// newTokenRequest builds a form-encoded request carrying the bearer token in
+// the btoken query parameter, as required by every write call of the API.
+func (bc *BboxClient) newTokenRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	token, err := bc.validToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := bc.NewRequestContext(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.URL.Query()
+	q.Set("btoken", token)
+	r.URL.RawQuery = q.Encode()
+	if body != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return r, nil
+}
+
+// Do sends the request. When the router answers 401 on a client that has
+// logged in before, the session is renewed and the request replayed once.
 func (bc *BboxClient) Do(req *http.Request) (*http.Response, error) {
-	return bc.Client.Do(req)
+	resp, err := bc.send(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || bc.password == "" {
+		return resp, err
+	}
+
+	retry, err := bc.replay(req)
+	if err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if err := bc.Auth().BasicAuthContext(req.Context(), bc.password); err != nil {
+		return nil, err
+	}
+	if q := retry.URL.Query(); q.Has("btoken") {
+		token, err := bc.validToken(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		q.Set("btoken", token)
+		retry.URL.RawQuery = q.Encode()
+	}
+	return bc.send(retry)
+}
+
+// DoContext sends the request bound to ctx instead of its own context
+func (bc *BboxClient) DoContext(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return bc.Do(req.WithContext(ctx))
+}
+
+// send is the single path of every request to the router: it applies the
+// retry policy and runs the hooks around each attempt, without any session
+// handling
+func (bc *BboxClient) send(req *http.Request) (*http.Response, error) {
+	return bc.sendWithRetry(req, bc.attempt)
+}
+
+// attempt sends the request once
+func (bc *BboxClient) attempt(req *http.Request) (*http.Response, error) {
+	for _, hook := range bc.before {
+		if err := hook(req); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := bc.Client.Do(req)
+	for _, hook := range bc.after {
+		hook(req, resp, err)
+	}
+	return resp, err
+}
+
+// replay returns a copy of req with a fresh body so it can be sent again
+func (bc *BboxClient) replay(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	// The cookie jar adds the session cookies to the request it sends; drop
+	// them so the renewed session is used
+	retry.Header.Del("Cookie")
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("request body cannot be replayed")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
 }
 
 func (bc *BboxClient) Get(url string) (*http.Response, error) {
-	return bc.Client.Get(bc.Url.JoinPath(url).String())
+	return bc.GetContext(context.Background(), url)
+}
+
+// GetContext is like Get but bound to ctx
+func (bc *BboxClient) GetContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := bc.NewRequestContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bc.Do(req)
 }
 
 func (bc *BboxClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
-	return bc.Client.Post(bc.Url.JoinPath(url).String(), contentType, body)
+	return bc.PostContext(context.Background(), url, contentType, body)
+}
+
+// PostContext is like Post but bound to ctx
+func (bc *BboxClient) PostContext(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := bc.NewRequestContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return bc.Do(req)
 }
 
 func (bc *BboxClient) Nat() *NatInterface {
@@ -60,6 +206,10 @@ func (bc *BboxClient) Firewall() *FirewallInterface {
 	return &FirewallInterface{Client: bc}
 }
 
+func (bc *BboxClient) Pinhole() *PinholeInterface {
+	return &PinholeInterface{Client: bc}
+}
+
 func (bc *BboxClient) Auth() *AuthInterface {
 	return &AuthInterface{Client: bc}
 }
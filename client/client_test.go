@@ -0,0 +1,227 @@
+package client_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	bboxclient "bbox-cli/client"
+	"bbox-cli/client/bboxtest"
+)
+
+const testPassword = "secret"
+
+// newTestClient starts a fake Bbox and returns a client logged into it
+func newTestClient(t *testing.T) (*bboxtest.Server, *bboxclient.BboxClient) {
+	t.Helper()
+
+	server := bboxtest.NewServer(testPassword)
+	t.Cleanup(server.Close)
+
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+	return server, client
+}
+
+func TestNewClient(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	client, err := bboxclient.NewClient(server.BaseURL())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if client.Client.Jar == nil {
+		t.Error("client has no cookie jar")
+	}
+	if client.Bearer() != nil {
+		t.Error("new client should not have a bearer token")
+	}
+}
+
+func TestNewRequestJoinsPath(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	client, _ := server.NewClient()
+	req, err := client.NewRequest("GET", "/firewall/rules", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if want := server.URL + "/api/v1/firewall/rules"; req.URL.String() != want {
+		t.Errorf("URL = %q, want %q", req.URL, want)
+	}
+}
+
+func TestGetCookies(t *testing.T) {
+	_, client := newTestClient(t)
+
+	var found bool
+	for _, c := range client.GetCookies() {
+		if c.Name == bboxtest.SessionCookie && c.Value != "" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("session cookie %s not stored after login", bboxtest.SessionCookie)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+	server.Latency = 200 * time.Millisecond
+
+	client, _ := bboxclient.NewClient(server.BaseURL(), bboxclient.WithTimeout(20*time.Millisecond))
+	if _, err := client.Get("/firewall/rules"); err == nil {
+		t.Error("request slower than the timeout succeeded")
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	server, client := newTestClient(t)
+	server.Latency = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Firewall().GetFirewallRulesContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("request took %v despite the context deadline", elapsed)
+	}
+}
+
+func TestMiddlewareAndHooks(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	var order []string
+	tag := func(name string) bboxclient.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return bboxclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				req.Header.Add("X-Test", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	var seen []string
+	var statuses []int
+	client, err := bboxclient.NewClient(server.BaseURL(),
+		bboxclient.WithMiddleware(tag("outer")),
+		bboxclient.WithMiddleware(tag("inner")),
+		bboxclient.WithBeforeRequest(func(req *http.Request) error {
+			seen = append(seen, req.Method+" "+req.URL.Path)
+			return nil
+		}),
+		bboxclient.WithAfterResponse(func(req *http.Request, resp *http.Response, err error) {
+			if err == nil {
+				statuses = append(statuses, resp.StatusCode)
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+	if err := client.Firewall().AddFirewallRule(bboxclient.FirewallRule{Description: "x"}); err != nil {
+		t.Fatalf("AddFirewallRule: %v", err)
+	}
+
+	// Login, token and the rule creation all go through the pipeline
+	if len(seen) != 3 || len(statuses) != 3 {
+		t.Fatalf("hooks saw %v with statuses %v, want 3 requests", seen, statuses)
+	}
+	if statuses[2] != http.StatusCreated {
+		t.Errorf("statuses = %v", statuses)
+	}
+	if order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("middleware order = %v, want outer before inner", order)
+	}
+}
+
+func TestBeforeHookAbortsRequest(t *testing.T) {
+	server, _ := newTestClient(t)
+
+	blocked := errors.New("blocked")
+	client, _ := bboxclient.NewClient(server.BaseURL(),
+		bboxclient.WithBeforeRequest(func(req *http.Request) error { return blocked }))
+	if err := client.Auth().BasicAuth(testPassword); !errors.Is(err, blocked) {
+		t.Errorf("err = %v, want the hook error", err)
+	}
+	if n := countRequests(server.Requests(), "POST /login"); n != 1 {
+		t.Errorf("router saw %d logins, want only the one of newTestClient", n)
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	failures := 1
+	flaky := func(next http.RoundTripper) http.RoundTripper {
+		return bboxclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet && failures > 0 {
+				failures--
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    req,
+				}, nil
+			}
+			return next.RoundTrip(req)
+		})
+	}
+	client, _ := bboxclient.NewClient(server.BaseURL(), bboxclient.WithMiddleware(flaky))
+	if err := client.Auth().BasicAuth(testPassword); err == nil {
+		t.Fatal("login succeeded despite the injected failure")
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+}
+
+func TestWithTransport(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	calls := 0
+	transport := bboxclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client, err := bboxclient.NewClient(server.BaseURL(), bboxclient.WithTransport(transport))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("transport used for %d requests, want 2", calls)
+	}
+
+	// The TLS configuration cannot be applied to a custom transport
+	_, err = bboxclient.NewClient(server.BaseURL(),
+		bboxclient.WithTransport(transport),
+		bboxclient.WithTLSConfig(&tls.Config{}))
+	if err == nil {
+		t.Error("NewClient accepted WithTLSConfig with a custom transport")
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// DeviceInfo describes the router hardware and firmware
+type DeviceInfo struct {
+	ModelName    string          `json:"modelname" yaml:"modelname"`
+	SerialNumber string          `json:"serialnumber" yaml:"serialnumber"`
+	Main         FirmwareVersion `json:"main" yaml:"main"`
+}
+
+// FirmwareVersion is the version of one firmware image
+type FirmwareVersion struct {
+	Version string `json:"version" yaml:"version"`
+	Date    string `json:"date" yaml:"date"`
+}
+
+// DeviceResponse wraps the device data from API responses
+type DeviceResponse struct {
+	Device DeviceInfo `json:"device" yaml:"device"`
+}
+
+// GetDeviceInfo retrieves the model and firmware of the router
+func (bc *BboxClient) GetDeviceInfo() (DeviceInfo, error) {
+	return bc.GetDeviceInfoContext(context.Background())
+}
+
+// GetDeviceInfoContext is like GetDeviceInfo but bound to ctx
+func (bc *BboxClient) GetDeviceInfoContext(ctx context.Context) (DeviceInfo, error) {
+	resp, err := bc.GetContext(ctx, "/device")
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := bc.checkResponse(resp, http.StatusOK); err != nil {
+		return DeviceInfo{}, err
+	}
+
+	var deviceResp []DeviceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&deviceResp); err != nil {
+		return DeviceInfo{}, err
+	}
+
+	if len(deviceResp) == 0 {
+		return DeviceInfo{}, errors.New("no device information in response")
+	}
+
+	return deviceResp[0].Device, nil
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody caps how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// APIError is returned when the router answers with an unexpected HTTP
+// status. It carries the error payload of the Bbox when there is one:
+//
+//	{"exception":{"domain":"v1/firewall/rules","code":"400","errors":[{"name":"srcip","reason":"Invalid"}]}}
+type APIError struct {
+	StatusCode int
+	Method     string
+	// Endpoint is the request path relative to the API root
+	Endpoint string
+
+	Domain string
+	Code   string
+	Errors []FieldError
+}
+
+// FieldError is one entry of the errors list of a Bbox error payload
+type FieldError struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("status %d from %s %s", e.StatusCode, e.Method, e.Endpoint)
+	if reason := e.Reason(); reason != "" {
+		msg += ": " + reason
+	}
+	return msg
+}
+
+// Reason joins the field errors reported by the router, e.g.
+// "srcip: Invalid, dstports: Invalid"
+func (e *APIError) Reason() string {
+	var parts []string
+	for _, fe := range e.Errors {
+		switch {
+		case fe.Name != "" && fe.Reason != "":
+			parts = append(parts, fe.Name+": "+fe.Reason)
+		case fe.Reason != "":
+			parts = append(parts, fe.Reason)
+		case fe.Name != "":
+			parts = append(parts, fe.Name)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Is makes errors.Is(err, ErrFirewallRuleNotFound), ErrNatRuleNotFound and
+// ErrPinholeNotFound match 404 answers of the matching endpoints
+func (e *APIError) Is(target error) bool {
+	if e.StatusCode != http.StatusNotFound {
+		return false
+	}
+	switch target {
+	case ErrFirewallRuleNotFound:
+		return strings.HasPrefix(e.Endpoint, "/firewall/rules")
+	case ErrNatRuleNotFound:
+		return strings.HasPrefix(e.Endpoint, "/nat/rules")
+	case ErrPinholeNotFound:
+		return strings.HasPrefix(e.Endpoint, "/firewall/pinhole")
+	}
+	return false
+}
+
+// IsUnauthorized reports whether err is a 401 answer: wrong password,
+// expired session or invalid btoken
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsRateLimited reports whether the router refused the request because too
+// many were made, as happens after repeated login attempts
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsNotFound reports whether err is a 404 answer or one of the rule not found
+// errors
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound) ||
+		errors.Is(err, ErrFirewallRuleNotFound) ||
+		errors.Is(err, ErrNatRuleNotFound) ||
+		errors.Is(err, ErrPinholeNotFound)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// LockoutError is returned by BasicAuth when the router refuses logins for a
+// while after repeated failures. Wait is zero when the router did not tell
+// how long.
+type LockoutError struct {
+	Wait time.Duration
+	Err  *APIError
+}
+
+func (e *LockoutError) Error() string {
+	if e.Wait <= 0 {
+		return "too many login attempts, the router refuses logins for a while"
+	}
+	return fmt.Sprintf("too many login attempts, retry in %s", e.Wait)
+}
+
+func (e *LockoutError) Unwrap() error {
+	return e.Err
+}
+
+// waitInReason finds the delay in reasons such as "Retry in 57 seconds"
+var waitInReason = regexp.MustCompile(`(\d+)\s*(?:s|secs?|seconds?)\b`)
+
+// newLockoutError reads the wait time from the Retry-After header, or else
+// from the reason given in the error payload
+func newLockoutError(resp *http.Response, apiErr *APIError) *LockoutError {
+	lockout := &LockoutError{Err: apiErr}
+	if header := resp.Header.Get("Retry-After"); header != "" {
+		if seconds, err := strconv.Atoi(header); err == nil {
+			lockout.Wait = time.Duration(seconds) * time.Second
+		} else if at, err := http.ParseTime(header); err == nil {
+			lockout.Wait = time.Until(at).Round(time.Second)
+		}
+		return lockout
+	}
+	if m := waitInReason.FindStringSubmatch(apiErr.Reason()); m != nil {
+		seconds, _ := strconv.Atoi(m[1])
+		lockout.Wait = time.Duration(seconds) * time.Second
+	}
+	return lockout
+}
+
+// checkResponse returns nil when resp has the wanted status and an
+// *APIError decoded from the body otherwise
+func (bc *BboxClient) checkResponse(resp *http.Response, want int) error {
+	if resp.StatusCode == want {
+		return nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	if req := resp.Request; req != nil {
+		apiErr.Method = req.Method
+		apiErr.Endpoint = "/" + strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, bc.Url.Path), "/")
+	}
+
+	var payload struct {
+		Exception struct {
+			Domain string       `json:"domain"`
+			Code   string       `json:"code"`
+			Errors []FieldError `json:"errors"`
+		} `json:"exception"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if json.Unmarshal(body, &payload) == nil {
+		apiErr.Domain = payload.Exception.Domain
+		apiErr.Code = payload.Exception.Code
+		apiErr.Errors = payload.Exception.Errors
+	}
+	return apiErr
+}
@@ -0,0 +1,66 @@
+package client_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func TestAPIErrorFromRouter(t *testing.T) {
+	server, logged := newTestClient(t)
+	session := logged.Session()
+	session.Bearer.Token = "stale"
+
+	// Without a known password the 401 is not retried and reaches the caller
+	client, _ := server.NewClient()
+	client.Auth().Resume(session, "")
+	err := client.Firewall().AddFirewallRule(bboxclient.FirewallRule{Description: "x"})
+
+	var apiErr *bboxclient.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Method != "POST" || apiErr.Endpoint != "/firewall/rules" {
+		t.Errorf("apiErr = %+v", apiErr)
+	}
+	if apiErr.Domain != "v1/firewall/rules" || apiErr.Code != "401" {
+		t.Errorf("payload not decoded: %+v", apiErr)
+	}
+	if apiErr.Reason() != "btoken: Invalid" {
+		t.Errorf("Reason() = %q", apiErr.Reason())
+	}
+	if !bboxclient.IsUnauthorized(err) {
+		t.Error("IsUnauthorized = false")
+	}
+}
+
+func TestAPIErrorNotFound(t *testing.T) {
+	_, client := newTestClient(t)
+
+	err := client.Nat().DeleteNatRule("404")
+	if !errors.Is(err, bboxclient.ErrNatRuleNotFound) {
+		t.Errorf("err = %v, want ErrNatRuleNotFound", err)
+	}
+	if errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		t.Error("NAT 404 matched ErrFirewallRuleNotFound")
+	}
+	if !bboxclient.IsNotFound(err) {
+		t.Error("IsNotFound = false")
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	limited := fmt.Errorf("login: %w", &bboxclient.APIError{StatusCode: http.StatusTooManyRequests})
+	if !bboxclient.IsRateLimited(limited) {
+		t.Error("IsRateLimited = false for a wrapped 429")
+	}
+	if bboxclient.IsUnauthorized(limited) || bboxclient.IsNotFound(limited) {
+		t.Error("429 matched another helper")
+	}
+	if !bboxclient.IsNotFound(bboxclient.ErrFirewallRuleNotFound) {
+		t.Error("IsNotFound = false for ErrFirewallRuleNotFound")
+	}
+}
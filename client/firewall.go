@@ -1,10 +1,13 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
@@ -17,12 +20,21 @@ type FirewallInterface struct {
 
 // GetFirewallRules retrieves all firewall rules from the device
 func (fi *FirewallInterface) GetFirewallRules() ([]FirewallRule, error) {
-	resp, err := fi.Client.Get("/firewall/rules")
+	return fi.GetFirewallRulesContext(context.Background())
+}
+
+// GetFirewallRulesContext is like GetFirewallRules but bound to ctx
+func (fi *FirewallInterface) GetFirewallRulesContext(ctx context.Context) ([]FirewallRule, error) {
+	resp, err := fi.Client.GetContext(ctx, "/firewall/rules")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if err := fi.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
 	var firewallResp []FirewallResponse
 	if err := json.NewDecoder(resp.Body).Decode(&firewallResp); err != nil {
 		return nil, err
@@ -37,12 +49,22 @@ func (fi *FirewallInterface) GetFirewallRules() ([]FirewallRule, error) {
 
 // DeleteFirewallRule removes a firewall rule by its ID
 func (fi *FirewallInterface) DeleteFirewallRule(ruleID string) error {
-	if fi.Client.Bearer == nil {
-		return errors.New("no bearer token available")
+	return fi.DeleteFirewallRuleContext(context.Background(), ruleID)
+}
+
+// DeleteFirewallRuleContext is like DeleteFirewallRule but bound to ctx
+func (fi *FirewallInterface) DeleteFirewallRuleContext(ctx context.Context, ruleID string) error {
+	id, err := strconv.Atoi(ruleID)
+	if err != nil {
+		return fi.deleteFirewallRule(ctx, ruleID)
 	}
+	return fi.journalFirewall(ctx, ChangeDelete, FirewallRule{ID: id}, func() error {
+		return fi.deleteFirewallRule(ctx, ruleID)
+	})
+}
 
-	url := fmt.Sprintf("/firewall/rules/%s?btoken=%s", ruleID, fi.Client.Bearer.Token)
-	r, err := http.NewRequest("DELETE", fi.Client.Url.String()+url, nil)
+func (fi *FirewallInterface) deleteFirewallRule(ctx context.Context, ruleID string) error {
+	r, err := fi.Client.newTokenRequest(ctx, "DELETE", "/firewall/rules/"+ruleID, nil)
 	if err != nil {
 		return err
 	}
@@ -53,8 +75,8 @@ func (fi *FirewallInterface) DeleteFirewallRule(ruleID string) error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to delete rule: status %d", resp.StatusCode)
+	if err := fi.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
 	}
 
 	return nil
@@ -62,72 +84,90 @@ func (fi *FirewallInterface) DeleteFirewallRule(ruleID string) error {
 
 // AddFirewallRule creates a new firewall rule
 func (fi *FirewallInterface) AddFirewallRule(rule FirewallRule) error {
-	if fi.Client.Bearer == nil {
-		return errors.New("no bearer token available")
-	}
+	return fi.AddFirewallRuleContext(context.Background(), rule)
+}
 
-	url := fmt.Sprintf("/firewall/rules?btoken=%s", fi.Client.Bearer.Token)
+// AddFirewallRuleContext is like AddFirewallRule but bound to ctx
+func (fi *FirewallInterface) AddFirewallRuleContext(ctx context.Context, rule FirewallRule) error {
+	return fi.journalFirewall(ctx, ChangeAdd, rule, func() error {
+		return fi.addFirewallRule(ctx, rule)
+	})
+}
+
+func (fi *FirewallInterface) addFirewallRule(ctx context.Context, rule FirewallRule) error {
 	data := rule.RuleAsString()
+	r, err := fi.Client.newTokenRequest(ctx, "POST", "/firewall/rules", strings.NewReader(data))
+	if err != nil {
+		return err
+	}
 
-	resp, err := fi.Client.Post(
-		url,
-		"application/x-www-form-urlencoded",
-		strings.NewReader(data),
-	)
+	resp, err := fi.Client.Do(r)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("failed to add rule: status %d", resp.StatusCode)
+	if err := fi.Client.checkResponse(resp, http.StatusCreated); err != nil {
+		return fmt.Errorf("failed to add rule: %w", err)
 	}
 
 	return nil
 }
 
-// UpdateFirewallRule modifies an existing firewall rule
+// UpdateFirewallRule replaces every field of the rule identified by rule.ID.
+// It returns an error matching ErrFirewallRuleNotFound when no rule has that
+// ID.
 func (fi *FirewallInterface) UpdateFirewallRule(rule FirewallRule) error {
-	if fi.Client.Bearer == nil {
-		return errors.New("no bearer token available")
-	}
+	return fi.UpdateFirewallRuleContext(context.Background(), rule)
+}
 
-	// Find the rule ID by description
-	rules, err := fi.GetFirewallRules()
-	if err != nil {
-		return err
-	}
+// UpdateFirewallRuleContext is like UpdateFirewallRule but bound to ctx
+func (fi *FirewallInterface) UpdateFirewallRuleContext(ctx context.Context, rule FirewallRule) error {
+	return fi.journalFirewall(ctx, ChangeUpdate, rule, func() error {
+		return fi.putFirewallRule(ctx, rule.ID, rule.RuleAsString())
+	})
+}
 
-	var ruleID string
-	for _, r := range rules {
-		if r.Description == rule.Description {
-			ruleID = fmt.Sprintf("%d", r.ID)
-			break
-		}
+// PatchFirewallRule sends only the changed fields to the rule with the given
+// ID, leaving the others as they are on the router. Field names are those of
+// DiffFirewallRules.
+func (fi *FirewallInterface) PatchFirewallRule(ruleID int, changes []FieldChange) error {
+	return fi.PatchFirewallRuleContext(context.Background(), ruleID, changes)
+}
+
+// PatchFirewallRuleContext is like PatchFirewallRule but bound to ctx
+func (fi *FirewallInterface) PatchFirewallRuleContext(ctx context.Context, ruleID int, changes []FieldChange) error {
+	if len(changes) == 0 {
+		return nil
 	}
+	v := url.Values{}
+	for _, c := range changes {
+		v.Set(c.Field, c.New)
+	}
+	return fi.journalFirewall(ctx, ChangeUpdate, FirewallRule{ID: ruleID}, func() error {
+		return fi.putFirewallRule(ctx, ruleID, v.Encode())
+	})
+}
 
-	// Prepare the update request
-	url := fmt.Sprintf("/firewall/rules/%s", ruleID)
-	data := rule.RuleAsString()
+func (fi *FirewallInterface) putFirewallRule(ctx context.Context, ruleID int, data string) error {
+	if ruleID <= 0 {
+		return fmt.Errorf("failed to update rule: no rule ID: %w", ErrFirewallRuleNotFound)
+	}
 
-	r, err := fi.Client.NewRequest("PUT", url, strings.NewReader(data))
+	path := fmt.Sprintf("/firewall/rules/%d", ruleID)
+	r, err := fi.Client.newTokenRequest(ctx, "PUT", path, strings.NewReader(data))
 	if err != nil {
 		return err
 	}
 
-	// Add bearer token to query parameters
-	q := r.URL.Query()
-	q.Add("btoken", fi.Client.Bearer.Token)
-	r.URL.RawQuery = q.Encode()
-
 	resp, err := fi.Client.Do(r)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to update rule: status %d", resp.StatusCode)
+	if err := fi.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to update rule: %w", err)
 	}
 
 	return nil
@@ -143,21 +183,20 @@ func GenerateUniqueDescription(base string) string {
 // RuleAsString converts the firewall rule to URL-encoded form data
 // for API requests
 func (r *FirewallRule) RuleAsString() string {
-	return fmt.Sprintf(
-		"enable=%d&action=%s&srcipnot=%v&srcip=%v&dstipnot=%v&dstip=%v&srcportnot=%v&srcports=%v&dstportnot=%v&dstports=%v&order=%d&protocols=%v&ipprotocol=%v&description=%v",
-		r.Enable,
-		r.Action,
-		r.SrcIPNot,
-		r.SrcIP,
-		r.DstIPNot,
-		r.DstIP,
-		r.SrcPortNot,
-		r.SrcPorts,
-		r.DstPortNot,
-		r.DstPorts,
-		r.Order,
-		r.Protocols,
-		r.IPProtocol,
-		r.Description,
-	)
+	v := url.Values{}
+	v.Set("enable", fmt.Sprintf("%d", r.Enable))
+	v.Set("action", string(r.Action))
+	v.Set("srcipnot", fmt.Sprintf("%d", r.SrcIPNot))
+	v.Set("srcip", r.SrcIP.String())
+	v.Set("dstipnot", fmt.Sprintf("%d", r.DstIPNot))
+	v.Set("dstip", r.DstIP.String())
+	v.Set("srcportnot", fmt.Sprintf("%d", r.SrcPortNot))
+	v.Set("srcports", r.SrcPorts.String())
+	v.Set("dstportnot", fmt.Sprintf("%d", r.DstPortNot))
+	v.Set("dstports", r.DstPorts.String())
+	v.Set("order", fmt.Sprintf("%d", r.Order))
+	v.Set("protocols", string(r.Protocols))
+	v.Set("ipprotocol", string(r.IPProtocol))
+	v.Set("description", r.Description)
+	return v.Encode()
 }
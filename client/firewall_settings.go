@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GetFirewallSettings retrieves the global firewall level and switches
+func (fi *FirewallInterface) GetFirewallSettings() (FirewallSettings, error) {
+	return fi.GetFirewallSettingsContext(context.Background())
+}
+
+// GetFirewallSettingsContext is like GetFirewallSettings but bound to ctx
+func (fi *FirewallInterface) GetFirewallSettingsContext(ctx context.Context) (FirewallSettings, error) {
+	resp, err := fi.Client.GetContext(ctx, "/firewall")
+	if err != nil {
+		return FirewallSettings{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := fi.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return FirewallSettings{}, err
+	}
+
+	var settingsResp []FirewallSettingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&settingsResp); err != nil {
+		return FirewallSettings{}, err
+	}
+
+	if len(settingsResp) == 0 {
+		return FirewallSettings{}, errors.New("no firewall settings in response")
+	}
+
+	return settingsResp[0].Firewall, nil
+}
+
+// SetFirewallLevel changes the global security level of the firewall
+func (fi *FirewallInterface) SetFirewallLevel(level FirewallLevel) error {
+	return fi.SetFirewallLevelContext(context.Background(), level)
+}
+
+// SetFirewallLevelContext is like SetFirewallLevel but bound to ctx
+func (fi *FirewallInterface) SetFirewallLevelContext(ctx context.Context, level FirewallLevel) error {
+	switch level {
+	case FirewallLevelLow, FirewallLevelMedium, FirewallLevelHigh, FirewallLevelCustom:
+	default:
+		return fmt.Errorf("invalid firewall level %q", level)
+	}
+
+	v := url.Values{}
+	v.Set("level", string(level))
+	if err := fi.putSetting(ctx, "/firewall", v); err != nil {
+		return fmt.Errorf("failed to set firewall level: %w", err)
+	}
+	return nil
+}
+
+// SetPingResponder turns the answer to pings from the WAN on or off
+func (fi *FirewallInterface) SetPingResponder(state EnableState) error {
+	return fi.SetPingResponderContext(context.Background(), state)
+}
+
+// SetPingResponderContext is like SetPingResponder but bound to ctx
+func (fi *FirewallInterface) SetPingResponderContext(ctx context.Context, state EnableState) error {
+	v := url.Values{}
+	v.Set("enable", fmt.Sprintf("%d", state))
+	if err := fi.putSetting(ctx, "/firewall/pingresponder", v); err != nil {
+		return fmt.Errorf("failed to set ping responder: %w", err)
+	}
+	return nil
+}
+
+// SetGamerMode turns the gamer mode of the firewall on or off
+func (fi *FirewallInterface) SetGamerMode(state EnableState) error {
+	return fi.SetGamerModeContext(context.Background(), state)
+}
+
+// SetGamerModeContext is like SetGamerMode but bound to ctx
+func (fi *FirewallInterface) SetGamerModeContext(ctx context.Context, state EnableState) error {
+	v := url.Values{}
+	v.Set("enable", fmt.Sprintf("%d", state))
+	if err := fi.putSetting(ctx, "/firewall/gamermode", v); err != nil {
+		return fmt.Errorf("failed to set gamer mode: %w", err)
+	}
+	return nil
+}
+
+// putSetting sends a form to one of the global firewall endpoints
+func (fi *FirewallInterface) putSetting(ctx context.Context, path string, form url.Values) error {
+	r, err := fi.Client.newTokenRequest(ctx, "PUT", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	resp, err := fi.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return fi.Client.checkResponse(resp, http.StatusOK)
+}
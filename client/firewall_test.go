@@ -0,0 +1,239 @@
+package client_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+	"bbox-cli/client/bboxtest"
+)
+
+func TestGetFirewallRules(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 3, Description: "ssh", Action: bboxclient.ActionAllow, DstPorts: "22"},
+		{ID: 7, Description: "block", Action: bboxclient.ActionDeny, SrcIP: "10.0.0.1"},
+	})
+
+	rules, err := client.Firewall().GetFirewallRules()
+	if err != nil {
+		t.Fatalf("GetFirewallRules: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	if rules[0].ID != 3 || rules[0].DstPorts != "22" {
+		t.Errorf("rules[0] = %+v", rules[0])
+	}
+	if rules[1].Action != bboxclient.ActionDeny || rules[1].SrcIP != "10.0.0.1" {
+		t.Errorf("rules[1] = %+v", rules[1])
+	}
+}
+
+func TestGetFirewallRulesRequiresSession(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	client, _ := server.NewClient()
+	if _, err := client.Firewall().GetFirewallRules(); err == nil {
+		t.Error("GetFirewallRules succeeded without logging in")
+	}
+}
+
+func TestAddFirewallRule(t *testing.T) {
+	server, client := newTestClient(t)
+
+	rule := bboxclient.FirewallRule{
+		Description: bboxclient.GenerateUniqueDescription("web & mail"),
+		Enable:      bboxclient.Enabled,
+		Action:      bboxclient.ActionAllow,
+		DstIP:       "192.168.1.20",
+		DstPorts:    "80,443",
+		Protocols:   bboxclient.ProtocolTCP,
+		IPProtocol:  bboxclient.IPProtocolIPv4,
+		Order:       1,
+	}
+	if err := client.Firewall().AddFirewallRule(rule); err != nil {
+		t.Fatalf("AddFirewallRule: %v", err)
+	}
+
+	stored := server.FirewallRules()
+	if len(stored) != 1 {
+		t.Fatalf("server has %d rules, want 1", len(stored))
+	}
+	got := stored[0]
+	if got.Description != rule.Description || got.DstPorts != "80,443" || got.Protocols != bboxclient.ProtocolTCP {
+		t.Errorf("stored rule = %+v", got)
+	}
+}
+
+func TestAddFirewallRuleWithoutToken(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	defer server.Close()
+
+	client, _ := server.NewClient()
+	if err := client.Firewall().AddFirewallRule(bboxclient.FirewallRule{}); err == nil {
+		t.Error("AddFirewallRule succeeded without a bearer token")
+	}
+}
+
+func TestUpdateFirewallRule(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 4, Description: "web-bbcli-1", Action: bboxclient.ActionAllow, DstPorts: "80"},
+	})
+
+	rule := server.FirewallRules()[0]
+	rule.Action = bboxclient.ActionDeny
+	rule.DstPorts = "8080"
+	if err := client.Firewall().UpdateFirewallRule(rule); err != nil {
+		t.Fatalf("UpdateFirewallRule: %v", err)
+	}
+
+	got := server.FirewallRules()[0]
+	if got.Action != bboxclient.ActionDeny || got.DstPorts != "8080" {
+		t.Errorf("stored rule = %+v", got)
+	}
+}
+
+func TestUpdateFirewallRuleByID(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "same", DstPorts: "80"},
+		{ID: 2, Description: "same", DstPorts: "443"},
+	})
+
+	rule := server.FirewallRules()[1]
+	rule.Description = "renamed"
+	if err := client.Firewall().UpdateFirewallRule(rule); err != nil {
+		t.Fatalf("UpdateFirewallRule: %v", err)
+	}
+	rules := server.FirewallRules()
+	if rules[0].Description != "same" || rules[1].Description != "renamed" {
+		t.Errorf("stored rules = %+v", rules)
+	}
+
+	for _, id := range []int{0, 42} {
+		rule.ID = id
+		if err := client.Firewall().UpdateFirewallRule(rule); !errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+			t.Errorf("updating rule %d: err = %v, want ErrFirewallRuleNotFound", id, err)
+		}
+	}
+}
+
+func TestPatchFirewallRule(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 3, Description: "web", Action: bboxclient.ActionAllow, DstPorts: "80", Order: 2},
+	})
+
+	old := server.FirewallRules()[0]
+	updated := old
+	updated.DstPorts = "8080"
+	// Changed on the router meanwhile: must not be overwritten
+	server.SetFirewallRules([]bboxclient.FirewallRule{func() bboxclient.FirewallRule {
+		r := old
+		r.Order = 7
+		return r
+	}()})
+
+	changes := bboxclient.DiffFirewallRules(old, updated)
+	if err := client.Firewall().PatchFirewallRule(3, changes); err != nil {
+		t.Fatalf("PatchFirewallRule: %v", err)
+	}
+
+	got := server.FirewallRules()[0]
+	if got.DstPorts != "8080" || got.Order != 7 || got.Action != bboxclient.ActionAllow {
+		t.Errorf("stored rule = %+v", got)
+	}
+}
+
+func TestDeleteFirewallRule(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{{ID: 1}, {ID: 2}})
+
+	if err := client.Firewall().DeleteFirewallRule("1"); err != nil {
+		t.Fatalf("DeleteFirewallRule: %v", err)
+	}
+	if rules := server.FirewallRules(); len(rules) != 1 || rules[0].ID != 2 {
+		t.Errorf("remaining rules = %+v", rules)
+	}
+
+	err := client.Firewall().DeleteFirewallRule("42")
+	if !errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		t.Errorf("deleting an unknown rule: err = %v, want ErrFirewallRuleNotFound", err)
+	}
+}
+
+func TestFirewallSettings(t *testing.T) {
+	server, client := newTestClient(t)
+	fw := client.Firewall()
+
+	settings, err := fw.GetFirewallSettings()
+	if err != nil {
+		t.Fatalf("GetFirewallSettings: %v", err)
+	}
+	if settings.Level != bboxclient.FirewallLevelMedium || settings.PingResponder != bboxclient.Enabled {
+		t.Errorf("settings = %+v", settings)
+	}
+
+	if err := fw.SetFirewallLevel(bboxclient.FirewallLevelHigh); err != nil {
+		t.Fatalf("SetFirewallLevel: %v", err)
+	}
+	if err := fw.SetPingResponder(bboxclient.Disabled); err != nil {
+		t.Fatalf("SetPingResponder: %v", err)
+	}
+	if err := fw.SetGamerMode(bboxclient.Enabled); err != nil {
+		t.Fatalf("SetGamerMode: %v", err)
+	}
+
+	want := bboxclient.FirewallSettings{
+		Enable:        bboxclient.Enabled,
+		Level:         bboxclient.FirewallLevelHigh,
+		PingResponder: bboxclient.Disabled,
+		GamerMode:     bboxclient.Enabled,
+	}
+	if got := server.FirewallSettings(); got != want {
+		t.Errorf("stored settings = %+v, want %+v", got, want)
+	}
+}
+
+func TestSetFirewallLevelRejectsUnknownLevel(t *testing.T) {
+	server, client := newTestClient(t)
+	before := len(server.Requests())
+
+	if err := client.Firewall().SetFirewallLevel("paranoid"); err == nil {
+		t.Fatal("SetFirewallLevel accepted an unknown level")
+	}
+	if n := len(server.Requests()); n != before {
+		t.Errorf("%d requests sent for an invalid level, want none", n-before)
+	}
+}
+
+func TestFirewallRuleAsString(t *testing.T) {
+	rule := bboxclient.FirewallRule{
+		Description: "a&b=c d",
+		Action:      bboxclient.ActionAllow,
+		SrcIP:       "10.0.0.1,10.0.0.2",
+		DstPorts:    "80,443",
+		Protocols:   bboxclient.Protocol("tcp,udp"),
+		Order:       3,
+	}
+	form, err := url.ParseQuery(rule.RuleAsString())
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	for field, want := range map[string]string{
+		"description": "a&b=c d",
+		"srcip":       "10.0.0.1,10.0.0.2",
+		"dstports":    "80,443",
+		"protocols":   "tcp,udp",
+		"order":       "3",
+		"dstportnot":  "0",
+	} {
+		if got := form.Get(field); got != want {
+			t.Errorf("%s = %q, want %q", field, got, want)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrChangeConflict is returned by UndoChange when the rule no longer looks
+// the way the change left it
+var ErrChangeConflict = errors.New("rule was changed since")
+
+// ChangeOp names the kind of mutation recorded in a Change
+type ChangeOp string
+
+const (
+	ChangeAdd     ChangeOp = "add"
+	ChangeUpdate  ChangeOp = "update"
+	ChangeDelete  ChangeOp = "delete"
+	ChangeEnable  ChangeOp = "enable"
+	ChangeDisable ChangeOp = "disable"
+)
+
+// Change is a mutation of one rule with the rule as it was before and after
+// it. Before is unset for additions and After for deletions. Seq, Profile and
+// User are left for the Journal to fill in.
+type Change struct {
+	Seq      int        `json:"seq" yaml:"seq"`
+	Time     time.Time  `json:"time" yaml:"time"`
+	Profile  string     `json:"profile,omitempty" yaml:"profile,omitempty"`
+	User     string     `json:"user,omitempty" yaml:"user,omitempty"`
+	Section  string     `json:"section" yaml:"section"`
+	Op       ChangeOp   `json:"op" yaml:"op"`
+	RuleID   int        `json:"rule_id" yaml:"rule_id"`
+	UndoneAt *time.Time `json:"undone_at,omitempty" yaml:"undone_at,omitempty"`
+	// Note explains why part of the change is missing, e.g. when the rule
+	// could not be read back after it was applied
+	Note string `json:"note,omitempty" yaml:"note,omitempty"`
+
+	FirewallBefore *FirewallRule `json:"firewall_before,omitempty" yaml:"firewall_before,omitempty"`
+	FirewallAfter  *FirewallRule `json:"firewall_after,omitempty" yaml:"firewall_after,omitempty"`
+	NatBefore      *NatRule      `json:"nat_before,omitempty" yaml:"nat_before,omitempty"`
+	NatAfter       *NatRule      `json:"nat_after,omitempty" yaml:"nat_after,omitempty"`
+}
+
+// Journal receives every change made through a client created with
+// WithJournal. The change has already been applied when Record is called, so
+// implementations report their own failures rather than fail the call.
+type Journal interface {
+	Record(change Change)
+}
+
+// String describes the change, e.g. `delete firewall rule "ssh" (ID 4)`
+func (c Change) String() string {
+	section := c.Section
+	if section == SnapshotNat {
+		section = "NAT"
+	}
+	return fmt.Sprintf("%s %s rule %q (ID %d)", c.Op, section, c.Name(), c.RuleID)
+}
+
+// Name is the description of the rule without the bboxcli marker
+func (c Change) Name() string {
+	var description string
+	switch {
+	case c.FirewallAfter != nil:
+		description = c.FirewallAfter.Description
+	case c.FirewallBefore != nil:
+		description = c.FirewallBefore.Description
+	case c.NatAfter != nil:
+		return natKey(*c.NatAfter)
+	case c.NatBefore != nil:
+		return natKey(*c.NatBefore)
+	}
+	base, _ := BaseDescription(description)
+	return base
+}
+
+// ReplaceRuleID points the change to the rule now identified by newID, which
+// is needed once a deleted rule has been re-created by an undo
+func (c *Change) ReplaceRuleID(oldID, newID int) {
+	if c.RuleID != oldID {
+		return
+	}
+	c.RuleID = newID
+	for _, rule := range []*FirewallRule{c.FirewallBefore, c.FirewallAfter} {
+		if rule != nil {
+			rule.ID = newID
+		}
+	}
+	for _, rule := range []*NatRule{c.NatBefore, c.NatAfter} {
+		if rule != nil {
+			rule.ID = newID
+		}
+	}
+}
+
+// journalFirewall runs mutate and records it with the rule read before and
+// after. rule is the rule being added or, for other operations, carries the ID
+// of the rule being changed. Without a journal mutate is simply called.
+func (fi *FirewallInterface) journalFirewall(ctx context.Context, op ChangeOp, rule FirewallRule, mutate func() error) error {
+	journal := fi.Client.journal
+	if journal == nil {
+		return mutate()
+	}
+
+	before, err := fi.GetFirewallRulesContext(ctx)
+	if err != nil {
+		return err
+	}
+	change := Change{Section: SnapshotFirewall, Op: op, RuleID: rule.ID}
+	if op != ChangeAdd {
+		current, ok := findFirewallRule(before, rule.ID)
+		if !ok {
+			return fmt.Errorf("failed to %s rule: %w", op, ErrFirewallRuleNotFound)
+		}
+		change.FirewallBefore = &current
+	}
+
+	if err := mutate(); err != nil {
+		return err
+	}
+
+	if op != ChangeDelete {
+		// The change is made, so it is recorded even if the rule cannot be
+		// read back
+		if after, err := fi.GetFirewallRulesContext(ctx); err != nil {
+			change.Note = fmt.Sprintf("rule not read back after the change: %v", err)
+			if op == ChangeAdd {
+				// Keep the rule as sent so the change can still be named
+				change.FirewallAfter = &rule
+			}
+		} else {
+			if op == ChangeAdd {
+				change.RuleID = createdFirewallRuleID(before, after, rule.Description)
+			}
+			if current, ok := findFirewallRule(after, change.RuleID); ok {
+				change.FirewallAfter = &current
+			} else if op == ChangeAdd {
+				change.Note = "new rule not found when reading the rules back"
+				change.FirewallAfter = &rule
+			}
+		}
+	}
+
+	change.Time = time.Now().UTC().Truncate(time.Second)
+	journal.Record(change)
+	return nil
+}
+
+// journalNat is the NAT counterpart of journalFirewall
+func (ni *NatInterface) journalNat(ctx context.Context, op ChangeOp, rule NatRule, mutate func() error) error {
+	journal := ni.Client.journal
+	if journal == nil {
+		return mutate()
+	}
+
+	before, err := ni.GetNatRulesContext(ctx)
+	if err != nil {
+		return err
+	}
+	change := Change{Section: SnapshotNat, Op: op, RuleID: rule.ID}
+	if op != ChangeAdd {
+		current, ok := findNatRule(before, rule.ID)
+		if !ok {
+			return fmt.Errorf("failed to %s NAT rule: %w", op, ErrNatRuleNotFound)
+		}
+		change.NatBefore = &current
+	}
+
+	if err := mutate(); err != nil {
+		return err
+	}
+
+	if op != ChangeDelete {
+		// The change is made, so it is recorded even if the rule cannot be
+		// read back
+		if after, err := ni.GetNatRulesContext(ctx); err != nil {
+			change.Note = fmt.Sprintf("rule not read back after the change: %v", err)
+			if op == ChangeAdd {
+				// Keep the rule as sent so the change can still be named
+				change.NatAfter = &rule
+			}
+		} else {
+			if op == ChangeAdd {
+				change.RuleID = createdNatRuleID(before, after, natKey(rule))
+			}
+			if current, ok := findNatRule(after, change.RuleID); ok {
+				change.NatAfter = &current
+			} else if op == ChangeAdd {
+				change.Note = "new rule not found when reading the rules back"
+				change.NatAfter = &rule
+			}
+		}
+	}
+
+	change.Time = time.Now().UTC().Truncate(time.Second)
+	journal.Record(change)
+	return nil
+}
+
+// UndoChange applies the inverse of a recorded change: it deletes added
+// rules, re-creates deleted ones and puts updated rules back the way they
+// were. Unless force is set it fails with ErrChangeConflict when the rule was
+// modified after the change. It returns the ID of the rule afterwards, which
+// differs from change.RuleID for re-created rules. The undo itself is not
+// journaled.
+func (bc *BboxClient) UndoChange(change Change, force bool) (int, error) {
+	return bc.UndoChangeContext(context.Background(), change, force)
+}
+
+// UndoChangeContext is like UndoChange but bound to ctx
+func (bc *BboxClient) UndoChangeContext(ctx context.Context, change Change, force bool) (int, error) {
+	switch change.Section {
+	case SnapshotFirewall:
+		return bc.Firewall().undoChange(ctx, change, force)
+	case SnapshotNat:
+		return bc.Nat().undoChange(ctx, change, force)
+	}
+	return 0, fmt.Errorf("cannot undo changes of %q rules", change.Section)
+}
+
+// errUnknownRuleID is returned when undoing an addition whose rule could not
+// be identified when it was recorded
+var errUnknownRuleID = errors.New("the journal does not record the ID of the added rule")
+
+func (fi *FirewallInterface) undoChange(ctx context.Context, change Change, force bool) (int, error) {
+	rules, err := fi.GetFirewallRulesContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if change.Op == ChangeDelete {
+		if change.FirewallBefore == nil {
+			return 0, errors.New("the journal does not record the deleted rule")
+		}
+		rule := *change.FirewallBefore
+		rule.ID = 0
+		if err := fi.addFirewallRule(ctx, rule); err != nil {
+			return 0, err
+		}
+		after, err := fi.GetFirewallRulesContext(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("rule re-created but its ID is unknown: %w", err)
+		}
+		return createdFirewallRuleID(rules, after, rule.Description), nil
+	}
+
+	if change.RuleID == 0 {
+		return 0, errUnknownRuleID
+	}
+	current, ok := findFirewallRule(rules, change.RuleID)
+	if !ok {
+		return 0, fmt.Errorf("rule %d: %w", change.RuleID, ErrFirewallRuleNotFound)
+	}
+	if !force && change.FirewallAfter != nil {
+		if diff := DiffFirewallRules(*change.FirewallAfter, current); len(diff) > 0 {
+			return 0, fmt.Errorf("%w: %s", ErrChangeConflict, diff[0])
+		}
+	}
+
+	if change.Op == ChangeAdd {
+		return change.RuleID, fi.deleteFirewallRule(ctx, strconv.Itoa(change.RuleID))
+	}
+	if change.FirewallBefore == nil {
+		return 0, errors.New("the journal does not record the previous rule")
+	}
+	return change.RuleID, fi.putFirewallRule(ctx, change.RuleID, change.FirewallBefore.RuleAsString())
+}
+
+func (ni *NatInterface) undoChange(ctx context.Context, change Change, force bool) (int, error) {
+	rules, err := ni.GetNatRulesContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if change.Op == ChangeDelete {
+		if change.NatBefore == nil {
+			return 0, errors.New("the journal does not record the deleted NAT rule")
+		}
+		rule := *change.NatBefore
+		rule.ID = 0
+		if err := ni.addNatRule(ctx, rule); err != nil {
+			return 0, err
+		}
+		after, err := ni.GetNatRulesContext(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("NAT rule re-created but its ID is unknown: %w", err)
+		}
+		return createdNatRuleID(rules, after, natKey(rule)), nil
+	}
+
+	if change.RuleID == 0 {
+		return 0, errUnknownRuleID
+	}
+	current, ok := findNatRule(rules, change.RuleID)
+	if !ok {
+		return 0, fmt.Errorf("NAT rule %d: %w", change.RuleID, ErrNatRuleNotFound)
+	}
+	if !force && change.NatAfter != nil {
+		if diff := diffNatRules(*change.NatAfter, current); len(diff) > 0 {
+			return 0, fmt.Errorf("%w: %s", ErrChangeConflict, diff[0])
+		}
+	}
+
+	if change.Op == ChangeAdd {
+		return change.RuleID, ni.deleteNatRule(ctx, strconv.Itoa(change.RuleID))
+	}
+	if change.NatBefore == nil {
+		return 0, errors.New("the journal does not record the previous NAT rule")
+	}
+	return change.RuleID, ni.updateNatRule(ctx, *change.NatBefore)
+}
+
+// LastChanged returns the time each rule of the section was last changed or
+// undone by the given profile, keyed by rule ID
+func LastChanged(changes []Change, profile, section string) map[int]time.Time {
+	last := make(map[int]time.Time)
+	for _, c := range changes {
+		if c.Profile != profile || c.Section != section {
+			continue
+		}
+		t := c.Time
+		if c.UndoneAt != nil && c.UndoneAt.After(t) {
+			t = *c.UndoneAt
+		}
+		if t.After(last[c.RuleID]) {
+			last[c.RuleID] = t
+		}
+	}
+	return last
+}
+
+func findFirewallRule(rules []FirewallRule, id int) (FirewallRule, bool) {
+	for _, rule := range rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return FirewallRule{}, false
+}
+
+func findNatRule(rules []NatRule, id int) (NatRule, bool) {
+	for _, rule := range rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return NatRule{}, false
+}
+
+// createdFirewallRuleID finds the rule that appeared between before and
+// after, preferring one with the given description in case another client
+// added rules concurrently. It returns 0 when there is none.
+func createdFirewallRuleID(before, after []FirewallRule, description string) int {
+	known := make(map[int]bool)
+	for _, rule := range before {
+		known[rule.ID] = true
+	}
+	id := 0
+	for _, rule := range sortedByID(after) {
+		if known[rule.ID] {
+			continue
+		}
+		if rule.Description == description {
+			return rule.ID
+		}
+		if id == 0 {
+			id = rule.ID
+		}
+	}
+	return id
+}
+
+// createdNatRuleID is the NAT counterpart of createdFirewallRuleID, matching
+// rules by natKey
+func createdNatRuleID(before, after []NatRule, key string) int {
+	known := make(map[int]bool)
+	for _, rule := range before {
+		known[rule.ID] = true
+	}
+	id := 0
+	for _, rule := range after {
+		if known[rule.ID] {
+			continue
+		}
+		if natKey(rule) == key {
+			return rule.ID
+		}
+		if id == 0 || rule.ID < id {
+			id = rule.ID
+		}
+	}
+	return id
+}
@@ -0,0 +1,254 @@
+package client_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+	"bbox-cli/client/bboxtest"
+)
+
+type memoryJournal struct {
+	changes []bboxclient.Change
+}
+
+func (j *memoryJournal) Record(change bboxclient.Change) {
+	j.changes = append(j.changes, change)
+}
+
+// newJournaledClient is like newTestClient but records changes in a journal
+func newJournaledClient(t *testing.T) (*bboxtest.Server, *bboxclient.BboxClient, *memoryJournal) {
+	t.Helper()
+
+	server := bboxtest.NewServer(testPassword)
+	t.Cleanup(server.Close)
+
+	journal := &memoryJournal{}
+	client, err := bboxclient.NewClient(server.BaseURL(), bboxclient.WithJournal(journal))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+	return server, client, journal
+}
+
+func TestJournalFirewallChanges(t *testing.T) {
+	server, client, journal := newJournaledClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "ssh", Action: bboxclient.ActionAllow, DstPorts: "22"},
+	})
+	fw := client.Firewall()
+
+	if err := fw.AddFirewallRule(bboxclient.FirewallRule{Description: "web", Action: bboxclient.ActionAllow, DstPorts: "80"}); err != nil {
+		t.Fatalf("AddFirewallRule: %v", err)
+	}
+	changes := []bboxclient.FieldChange{{Field: "dstports", Old: "22", New: "2222"}}
+	if err := fw.PatchFirewallRule(1, changes); err != nil {
+		t.Fatalf("PatchFirewallRule: %v", err)
+	}
+	if err := fw.DeleteFirewallRule("1"); err != nil {
+		t.Fatalf("DeleteFirewallRule: %v", err)
+	}
+
+	if len(journal.changes) != 3 {
+		t.Fatalf("got %d changes, want 3", len(journal.changes))
+	}
+	add, update, del := journal.changes[0], journal.changes[1], journal.changes[2]
+	if add.Op != bboxclient.ChangeAdd || add.RuleID != 2 || add.FirewallBefore != nil || add.FirewallAfter == nil {
+		t.Errorf("add = %+v", add)
+	}
+	if update.Op != bboxclient.ChangeUpdate || update.FirewallBefore.DstPorts != "22" || update.FirewallAfter.DstPorts != "2222" {
+		t.Errorf("update = %+v", update)
+	}
+	if del.Op != bboxclient.ChangeDelete || del.FirewallBefore.DstPorts != "2222" || del.FirewallAfter != nil {
+		t.Errorf("delete = %+v", del)
+	}
+	if got := del.String(); got != `delete firewall rule "ssh" (ID 1)` {
+		t.Errorf("String() = %q", got)
+	}
+	if add.Time.IsZero() {
+		t.Error("change time not set")
+	}
+}
+
+func TestJournalSkipsFailedChanges(t *testing.T) {
+	_, client, journal := newJournaledClient(t)
+
+	err := client.Firewall().DeleteFirewallRule("42")
+	if !errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		t.Errorf("err = %v, want ErrFirewallRuleNotFound", err)
+	}
+	if len(journal.changes) != 0 {
+		t.Errorf("journaled %d changes, want 0", len(journal.changes))
+	}
+}
+
+func TestJournalRecordsChangeWhenReadBackFails(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	t.Cleanup(server.Close)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "ssh", Action: bboxclient.ActionAllow, DstPorts: "22"},
+	})
+
+	// Once a rule has been changed, reading the rules fails
+	mutated := false
+	mw := func(next http.RoundTripper) http.RoundTripper {
+		return bboxclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet && mutated && strings.Contains(req.URL.Path, "/rules") {
+				return nil, errors.New("connection reset by peer")
+			}
+			resp, err := next.RoundTrip(req)
+			if req.Method != http.MethodGet && strings.Contains(req.URL.Path, "/rules") {
+				mutated = true
+			}
+			return resp, err
+		})
+	}
+	journal := &memoryJournal{}
+	client, err := bboxclient.NewClient(server.BaseURL(), bboxclient.WithJournal(journal),
+		bboxclient.WithMiddleware(mw), bboxclient.WithMaxAttempts(1))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+
+	changes := []bboxclient.FieldChange{{Field: "dstports", Old: "22", New: "2222"}}
+	if err := client.Firewall().PatchFirewallRule(1, changes); err != nil {
+		t.Fatalf("PatchFirewallRule: %v", err)
+	}
+	if len(journal.changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(journal.changes))
+	}
+	update := journal.changes[0]
+	if update.RuleID != 1 || update.FirewallBefore == nil || update.FirewallAfter != nil || update.Note == "" {
+		t.Errorf("update = %+v, want rule 1 with a before state and a note", update)
+	}
+}
+
+func TestJournalNotesUnknownNewRule(t *testing.T) {
+	server := bboxtest.NewServer(testPassword)
+	t.Cleanup(server.Close)
+
+	// Another client removes the rule right after it is created
+	mw := func(next http.RoundTripper) http.RoundTripper {
+		return bboxclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/firewall/rules") {
+				server.SetFirewallRules(nil)
+			}
+			return resp, err
+		})
+	}
+	journal := &memoryJournal{}
+	client, err := bboxclient.NewClient(server.BaseURL(), bboxclient.WithJournal(journal), bboxclient.WithMiddleware(mw))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+
+	if err := client.Firewall().AddFirewallRule(bboxclient.FirewallRule{Description: "web", Action: bboxclient.ActionAllow}); err != nil {
+		t.Fatalf("AddFirewallRule: %v", err)
+	}
+	if len(journal.changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(journal.changes))
+	}
+	add := journal.changes[0]
+	if add.RuleID != 0 || add.Note == "" || add.FirewallAfter == nil || add.FirewallAfter.Description != "web" {
+		t.Errorf("add = %+v, want no rule ID, a note and the rule as sent", add)
+	}
+	if _, err := client.UndoChange(add, true); err == nil {
+		t.Error("UndoChange of an add without rule ID succeeded")
+	}
+}
+
+func TestUndoChanges(t *testing.T) {
+	server, client, journal := newJournaledClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "ssh", Action: bboxclient.ActionAllow, DstPorts: "22"},
+	})
+	server.SetNatRules([]bboxclient.NatRule{
+		{ID: 1, Description: "game", Enable: bboxclient.Enabled, Protocol: "tcp", SrcPorts: "3074", TargetIP: "192.168.1.10", TargetPorts: "3074"},
+	})
+
+	if err := client.Nat().DisableNatRule("1"); err != nil {
+		t.Fatalf("DisableNatRule: %v", err)
+	}
+	if err := client.Firewall().DeleteFirewallRule("1"); err != nil {
+		t.Fatalf("DeleteFirewallRule: %v", err)
+	}
+	if err := client.Firewall().AddFirewallRule(bboxclient.FirewallRule{Description: "web", DstPorts: "80"}); err != nil {
+		t.Fatalf("AddFirewallRule: %v", err)
+	}
+
+	// Newest first, as bboxcli undo does
+	for i := len(journal.changes) - 1; i >= 0; i-- {
+		id, err := client.UndoChange(journal.changes[i], false)
+		if err != nil {
+			t.Fatalf("undo %s: %v", journal.changes[i], err)
+		}
+		if journal.changes[i].Op == bboxclient.ChangeDelete && id == journal.changes[i].RuleID {
+			t.Errorf("re-created rule kept ID %d", id)
+		}
+	}
+
+	rules := server.FirewallRules()
+	if len(rules) != 1 || rules[0].Description != "ssh" || rules[0].DstPorts != "22" {
+		t.Errorf("firewall rules = %+v", rules)
+	}
+	if nat := server.NatRules(); nat[0].Enable != bboxclient.Enabled {
+		t.Errorf("NAT rule not re-enabled: %+v", nat[0])
+	}
+	if len(journal.changes) != 3 {
+		t.Errorf("undo was journaled: %d changes", len(journal.changes))
+	}
+}
+
+func TestUndoChangeConflict(t *testing.T) {
+	server, client, journal := newJournaledClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "ssh", DstPorts: "22"},
+	})
+
+	rule := bboxclient.FirewallRule{ID: 1, Description: "ssh", DstPorts: "2222"}
+	if err := client.Firewall().UpdateFirewallRule(rule); err != nil {
+		t.Fatalf("UpdateFirewallRule: %v", err)
+	}
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "ssh", DstPorts: "8022"},
+	})
+
+	_, err := client.UndoChange(journal.changes[0], false)
+	if !errors.Is(err, bboxclient.ErrChangeConflict) {
+		t.Fatalf("err = %v, want ErrChangeConflict", err)
+	}
+	if _, err := client.UndoChange(journal.changes[0], true); err != nil {
+		t.Fatalf("forced undo: %v", err)
+	}
+	if got := server.FirewallRules()[0].DstPorts; got != "22" {
+		t.Errorf("dstports = %q, want 22", got)
+	}
+}
+
+func TestChangeReplaceRuleID(t *testing.T) {
+	change := bboxclient.Change{
+		RuleID:         4,
+		FirewallBefore: &bboxclient.FirewallRule{ID: 4},
+		FirewallAfter:  &bboxclient.FirewallRule{ID: 4},
+	}
+	change.ReplaceRuleID(3, 9)
+	if change.RuleID != 4 {
+		t.Errorf("changed a change of another rule")
+	}
+	change.ReplaceRuleID(4, 9)
+	if change.RuleID != 9 || change.FirewallBefore.ID != 9 || change.FirewallAfter.ID != 9 {
+		t.Errorf("change = %+v", change)
+	}
+}
@@ -0,0 +1,212 @@
+package client
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// LintCheck names one of the problems LintFirewallRules looks for
+type LintCheck string
+
+const (
+	// LintShadowed: an earlier rule matches every packet of this one, so it
+	// never takes effect
+	LintShadowed LintCheck = "shadowed"
+	// LintDuplicate: another rule matches the same packets with the same
+	// action
+	LintDuplicate LintCheck = "duplicate"
+	// LintConflict: an earlier rule matches some of the same packets with
+	// the opposite action
+	LintConflict LintCheck = "conflict"
+	// LintWideAccept: an Accept rule open to any source and destination
+	LintWideAccept LintCheck = "wide-accept"
+	// LintDisabled: a disabled rule not changed for LintOptions.DisabledFor.
+	// The router records no modification date, so the age comes from the
+	// journal and rules without a recorded change are reported too.
+	LintDisabled LintCheck = "disabled"
+	// LintEmpty: a rule that matches no traffic at all, e.g. one negating
+	// ANY
+	LintEmpty LintCheck = "empty"
+	// LintInvalid: a rule whose fields cannot be parsed
+	LintInvalid LintCheck = "invalid"
+)
+
+// LintChecks returns every check LintFirewallRules runs
+func LintChecks() []LintCheck {
+	return []LintCheck{LintShadowed, LintDuplicate, LintConflict, LintWideAccept, LintDisabled, LintEmpty, LintInvalid}
+}
+
+// LintOptions tunes LintFirewallRules. The zero value reports every disabled
+// rule.
+type LintOptions struct {
+	// LastChanged maps rule IDs to the time they were last changed, see
+	// LastChanged
+	LastChanged map[int]time.Time
+	// DisabledFor is how long a disabled rule must be left unchanged
+	// before it is reported
+	DisabledFor time.Duration
+	// Now defaults to the current time
+	Now time.Time
+}
+
+// LintSeverity tells whether a finding is a mistake in the rule set or only
+// worth a look
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// Severity returns how serious findings of this check are. Disabled rules are
+// often kept on purpose, so they only get a warning.
+func (c LintCheck) Severity() LintSeverity {
+	if c == LintDisabled {
+		return LintWarning
+	}
+	return LintError
+}
+
+// LintFinding is one problem found in a rule set. Related is the ID of the
+// other rule involved, or 0.
+type LintFinding struct {
+	Check    LintCheck    `json:"check" yaml:"check"`
+	Severity LintSeverity `json:"severity" yaml:"severity"`
+	RuleID   int          `json:"rule" yaml:"rule"`
+	Related  int          `json:"related,omitempty" yaml:"related,omitempty"`
+	Message  string       `json:"message" yaml:"message"`
+}
+
+func (f LintFinding) String() string {
+	if f.Severity == LintWarning {
+		return fmt.Sprintf("%s (warning): %s", f.Check, f.Message)
+	}
+	return fmt.Sprintf("%s: %s", f.Check, f.Message)
+}
+
+// LintFailed reports whether any finding is an error rather than a warning
+func LintFailed(findings []LintFinding) bool {
+	for _, f := range findings {
+		if f.Severity != LintWarning {
+			return true
+		}
+	}
+	return false
+}
+
+// LintFirewallRules reports shadowed, duplicate, conflicting, overly wide,
+// empty and long disabled rules. Rules are compared in the order the router
+// applies them; disabled rules only get the disabled finding.
+func LintFirewallRules(rules []FirewallRule, opts LintOptions) []LintFinding {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var findings []LintFinding
+	add := func(check LintCheck, rule *FirewallRule, related *FirewallRule, format string, args ...interface{}) {
+		f := LintFinding{Check: check, Severity: check.Severity(), RuleID: rule.ID, Message: fmt.Sprintf(format, args...)}
+		if related != nil {
+			f.Related = related.ID
+		}
+		findings = append(findings, f)
+	}
+
+	type parsed struct {
+		rule  FirewallRule
+		match matchSet
+	}
+	var active []parsed
+
+	for _, rule := range sortedCopy(rules) {
+		rule := rule
+		if rule.Enable != Enabled {
+			changed, ok := opts.LastChanged[rule.ID]
+			switch {
+			case !ok:
+				add(LintDisabled, &rule, nil, "%s is disabled and has no recorded change; delete it if it is no longer needed",
+					lintName(rule))
+			case now.Sub(changed) >= opts.DisabledFor:
+				add(LintDisabled, &rule, nil, "%s is disabled and unchanged since %s; delete it if it is no longer needed",
+					lintName(rule), changed.Local().Format("2006-01-02"))
+			}
+			continue
+		}
+
+		match, err := newMatchSet(&rule)
+		if err != nil {
+			add(LintInvalid, &rule, nil, "%s cannot be evaluated: %v", lintName(rule), err)
+			continue
+		}
+		if match.empty() {
+			add(LintEmpty, &rule, nil, "%s matches nothing: its negations exclude all traffic", lintName(rule))
+			continue
+		}
+
+		if rule.Action == ActionAllow && isWide(match) {
+			add(LintWideAccept, &rule, nil, "%s accepts traffic from any source to any destination", lintName(rule))
+		}
+
+		for _, earlier := range active {
+			other := earlier.rule
+			switch {
+			case rule.Action == other.Action && match.equal(earlier.match):
+				kind := "near duplicate"
+				if sameMatchFields(rule, other) {
+					kind = "duplicate"
+				}
+				add(LintDuplicate, &rule, &other, "%s is a %s of %s", lintName(rule), kind, lintName(other))
+			case earlier.match.contains(match):
+				add(LintShadowed, &rule, &other, "%s never applies: %s (order %d) matches all its traffic first",
+					lintName(rule), lintName(other), other.Order)
+			case rule.Action != other.Action && earlier.match.intersects(match):
+				add(LintConflict, &rule, &other, "%s overlaps %s (order %d); their shared traffic gets %s, not %s",
+					lintName(rule), lintName(other), other.Order, other.Action, rule.Action)
+			default:
+				continue
+			}
+			// Report each rule against the first rule it collides with only
+			break
+		}
+		active = append(active, parsed{rule: rule, match: match})
+	}
+	return findings
+}
+
+// isWide reports whether a match set accepts every source and destination
+// address of at least one IP version, on any destination port
+func isWide(m matchSet) bool {
+	if !m.dstPorts.contains(spanSet[port]{allPort}) {
+		return false
+	}
+	for ipv6, f := range m.families {
+		all := spanSet[netip.Addr]{allIPv4}
+		if ipv6 {
+			all = spanSet[netip.Addr]{allIPv6}
+		}
+		if f.src.contains(all) && f.dst.contains(all) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameMatchFields reports whether two rules have identical match fields as
+// written, as opposed to equivalent ones like "80,443" and "443,80"
+func sameMatchFields(a, b FirewallRule) bool {
+	a, b = a.WithDefaults(), b.WithDefaults()
+	return a.SrcIP == b.SrcIP && a.SrcIPNot == b.SrcIPNot &&
+		a.DstIP == b.DstIP && a.DstIPNot == b.DstIPNot &&
+		a.SrcPorts == b.SrcPorts && a.SrcPortNot == b.SrcPortNot &&
+		a.DstPorts == b.DstPorts && a.DstPortNot == b.DstPortNot &&
+		a.Protocols == b.Protocols && a.IPProtocol == b.IPProtocol
+}
+
+func lintName(rule FirewallRule) string {
+	base, _ := BaseDescription(rule.Description)
+	if base == "" {
+		return fmt.Sprintf("rule %d", rule.ID)
+	}
+	return fmt.Sprintf("rule %d %q", rule.ID, base)
+}
@@ -0,0 +1,144 @@
+package client_test
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	bboxclient "bbox-cli/client"
+)
+
+// lintChecks summarises findings as sorted "rule check related" strings
+func lintChecks(findings []bboxclient.LintFinding) []string {
+	var checks []string
+	for _, f := range findings {
+		checks = append(checks, fmt.Sprintf("%d %s %d", f.RuleID, f.Check, f.Related))
+	}
+	sort.Strings(checks)
+	return checks
+}
+
+func TestLintFirewallRules(t *testing.T) {
+	on := bboxclient.Enabled
+	rules := []bboxclient.FirewallRule{
+		{ID: 1, Order: 1, Enable: on, Action: bboxclient.ActionDeny, SrcIP: "10.0.0.0/8"},
+		// Inside rule 1
+		{ID: 2, Order: 2, Enable: on, Action: bboxclient.ActionAllow, SrcIP: "10.1.2.3", DstPorts: "22"},
+		// Same traffic as rule 4, written differently
+		{ID: 3, Order: 3, Enable: on, Action: bboxclient.ActionAllow, DstPorts: "80,443", SrcIP: "192.168.0.0/16"},
+		{ID: 4, Order: 4, Enable: on, Action: bboxclient.ActionAllow, DstPorts: "443,80", SrcIP: "192.168.0.0-192.168.255.255"},
+		// Overlaps rule 3 on port 443 only
+		{ID: 5, Order: 5, Enable: on, Action: bboxclient.ActionDeny, DstPorts: "443-450", SrcIP: "192.168.1.0/24"},
+		{ID: 6, Order: 6, Enable: bboxclient.Disabled, Action: bboxclient.ActionDeny},
+		{ID: 7, Order: 7, Enable: on, Action: bboxclient.ActionAllow, Protocols: bboxclient.ProtocolTCP},
+		// Unrelated to everything above
+		{ID: 8, Order: 8, Enable: on, Action: bboxclient.ActionDeny, SrcIP: "172.16.0.1", DstPorts: "25",
+			Protocols: bboxclient.ProtocolUDP},
+	}
+
+	got := lintChecks(bboxclient.LintFirewallRules(rules, bboxclient.LintOptions{}))
+	want := []string{
+		"2 shadowed 1",
+		"4 duplicate 3",
+		"5 conflict 3",
+		"6 disabled 0",
+		// Rule 7 lets through TCP that rule 1 drops
+		"7 conflict 1",
+		"7 wide-accept 0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+}
+
+func TestLintNegatedRules(t *testing.T) {
+	on := bboxclient.Enabled
+	rules := []bboxclient.FirewallRule{
+		// Everything except 10.0.0.0/8 on port 22
+		{ID: 1, Order: 1, Enable: on, Action: bboxclient.ActionDeny, SrcIP: "10.0.0.0/8", SrcIPNot: on, DstPorts: "22"},
+		// Contained in rule 1
+		{ID: 2, Order: 2, Enable: on, Action: bboxclient.ActionAllow, SrcIP: "192.168.1.5", DstPorts: "22"},
+		// Outside of rule 1
+		{ID: 3, Order: 3, Enable: on, Action: bboxclient.ActionAllow, SrcIP: "10.0.0.5", DstPorts: "22"},
+	}
+
+	got := lintChecks(bboxclient.LintFirewallRules(rules, bboxclient.LintOptions{}))
+	if want := []string{"2 shadowed 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+}
+
+func TestLintInvalidRule(t *testing.T) {
+	rules := []bboxclient.FirewallRule{{ID: 1, Enable: bboxclient.Enabled, SrcIP: "nope"}}
+	findings := bboxclient.LintFirewallRules(rules, bboxclient.LintOptions{})
+	if len(findings) != 1 || findings[0].Check != bboxclient.LintInvalid {
+		t.Errorf("findings = %v, want one invalid", findings)
+	}
+}
+
+func TestLintDisabledIsWarning(t *testing.T) {
+	rules := []bboxclient.FirewallRule{
+		{ID: 1, Order: 1, Enable: bboxclient.Disabled, Action: bboxclient.ActionDeny, DstPorts: "22"},
+		{ID: 2, Order: 2, Enable: bboxclient.Enabled, Action: bboxclient.ActionAllow, DstPorts: "80",
+			SrcIP: "192.168.1.0/24"},
+	}
+	findings := bboxclient.LintFirewallRules(rules, bboxclient.LintOptions{})
+	if len(findings) != 1 || findings[0].Severity != bboxclient.LintWarning {
+		t.Fatalf("findings = %v, want one disabled warning", findings)
+	}
+	if bboxclient.LintFailed(findings) {
+		t.Error("LintFailed = true for a disabled rule alone")
+	}
+
+	rules = append(rules, bboxclient.FirewallRule{ID: 3, Order: 3, Enable: bboxclient.Enabled,
+		Action: bboxclient.ActionAllow, DstPorts: "80", SrcIP: "192.168.1.7"})
+	if findings := bboxclient.LintFirewallRules(rules, bboxclient.LintOptions{}); !bboxclient.LintFailed(findings) {
+		t.Errorf("LintFailed = false with a shadowed rule, findings %v", findings)
+	}
+}
+
+func TestLintEmptyRule(t *testing.T) {
+	on := bboxclient.Enabled
+	rules := []bboxclient.FirewallRule{
+		{ID: 1, Order: 1, Enable: on, Action: bboxclient.ActionDeny, DstPorts: "22"},
+		// Negating every IPv4 source leaves nothing to match
+		{ID: 2, Order: 2, Enable: on, Action: bboxclient.ActionDeny, SrcIP: "0.0.0.0/0", SrcIPNot: on, DstPorts: "22"},
+	}
+
+	got := lintChecks(bboxclient.LintFirewallRules(rules, bboxclient.LintOptions{}))
+	if want := []string{"2 empty 0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+}
+
+func TestLintDisabledAge(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	off := bboxclient.Disabled
+	rules := []bboxclient.FirewallRule{
+		{ID: 1, Order: 1, Enable: off, Action: bboxclient.ActionDeny},
+		{ID: 2, Order: 2, Enable: off, Action: bboxclient.ActionDeny},
+		{ID: 3, Order: 3, Enable: off, Action: bboxclient.ActionDeny},
+	}
+	recent := now.Add(-time.Hour)
+	changes := []bboxclient.Change{
+		{Profile: "home", Section: bboxclient.SnapshotFirewall, RuleID: 1, Time: now.AddDate(0, -2, 0)},
+		// Undoing a change counts as touching the rule
+		{Profile: "home", Section: bboxclient.SnapshotFirewall, RuleID: 2, Time: now.AddDate(0, -2, 0), UndoneAt: &recent},
+		// Other profiles and sections do not count
+		{Profile: "work", Section: bboxclient.SnapshotFirewall, RuleID: 1, Time: recent},
+		{Profile: "home", Section: bboxclient.SnapshotNat, RuleID: 1, Time: recent},
+	}
+
+	opts := bboxclient.LintOptions{
+		LastChanged: bboxclient.LastChanged(changes, "home", bboxclient.SnapshotFirewall),
+		DisabledFor: 30 * 24 * time.Hour,
+		Now:         now,
+	}
+	got := lintChecks(bboxclient.LintFirewallRules(rules, opts))
+	// Rule 2 was touched recently, rule 3 has no recorded change
+	if want := []string{"1 disabled 0", "3 disabled 0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Packet describes the traffic evaluated against firewall rules. Zero
+// addresses and ports are unknown and only match rules accepting ANY value
+// for that field.
+type Packet struct {
+	IPv6     bool
+	SrcIP    netip.Addr
+	DstIP    netip.Addr
+	SrcPort  int
+	DstPort  int
+	Protocol Protocol
+}
+
+// Matches reports whether the rule's match fields all cover the packet. The
+// Enable flag is not considered.
+func (r *FirewallRule) Matches(p Packet) (bool, error) {
+	if !r.coversIPVersion(p.IPv6) || !r.coversProtocol(p.Protocol) {
+		return false, nil
+	}
+
+	for _, m := range []struct {
+		field string
+		match func() (bool, error)
+	}{
+		{"srcip", func() (bool, error) { return addressMatches(r.SrcIP, r.SrcIPNot, p.SrcIP) }},
+		{"dstip", func() (bool, error) { return addressMatches(r.DstIP, r.DstIPNot, p.DstIP) }},
+		{"srcports", func() (bool, error) { return portMatches(r.SrcPorts, r.SrcPortNot, p.SrcPort) }},
+		{"dstports", func() (bool, error) { return portMatches(r.DstPorts, r.DstPortNot, p.DstPort) }},
+	} {
+		ok, err := m.match()
+		if err != nil {
+			return false, fmt.Errorf("rule %d: %s: %w", r.ID, m.field, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// FirewallMatches returns the enabled rules matching the packet in the order
+// the router evaluates them
+func FirewallMatches(rules []FirewallRule, p Packet) ([]FirewallRule, error) {
+	sorted := sortedCopy(rules)
+	var matches []FirewallRule
+	for i := range sorted {
+		if sorted[i].Enable != Enabled {
+			continue
+		}
+		ok, err := sorted[i].Matches(p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, sorted[i])
+		}
+	}
+	return matches, nil
+}
+
+// EvaluateFirewall returns the first enabled rule matching the packet, which
+// decides its fate, or false when no rule matches and the default policy of
+// the firewall level applies
+func EvaluateFirewall(rules []FirewallRule, p Packet) (FirewallRule, bool, error) {
+	matches, err := FirewallMatches(rules, p)
+	if err != nil || len(matches) == 0 {
+		return FirewallRule{}, false, err
+	}
+	return matches[0], true, nil
+}
+
+func (r *FirewallRule) coversIPVersion(ipv6 bool) bool {
+	switch r.IPProtocol {
+	case IPProtocolBoth:
+		return true
+	case IPProtocolIPv6:
+		return ipv6
+	default:
+		return !ipv6
+	}
+}
+
+func (r *FirewallRule) coversProtocol(protocol Protocol) bool {
+	protocols := r.Protocols
+	if protocols == "" {
+		protocols = ProtocolAny
+	}
+	covered := make(map[Protocol]bool)
+	for _, p := range strings.Split(string(protocols), ",") {
+		covered[Protocol(strings.TrimSpace(p))] = true
+	}
+	if protocol == "" {
+		// Unknown protocol: only rules covering every protocol apply
+		return covered[ProtocolTCP] && covered[ProtocolUDP]
+	}
+	return covered[protocol]
+}
+
+// addressMatches evaluates a comma-separated address field of a rule
+func addressMatches(spec StringOrInt, not EnableState, addr netip.Addr) (bool, error) {
+	if spec == "" {
+		return true, nil
+	}
+	if !addr.IsValid() {
+		return false, nil
+	}
+
+	matches, err := ParseAddressList(spec.String())
+	if err != nil {
+		return false, err
+	}
+	found := false
+	for _, m := range matches {
+		if m.Contains(addr) {
+			found = true
+		}
+	}
+	return found != (not == Enabled), nil
+}
+
+// portMatches evaluates a port list field of a rule
+func portMatches(spec StringOrInt, not EnableState, port int) (bool, error) {
+	if spec == "" {
+		return true, nil
+	}
+	if port == 0 {
+		return false, nil
+	}
+
+	ranges, err := ParsePortList(spec.String())
+	if err != nil {
+		return false, err
+	}
+	found := false
+	for _, pr := range ranges {
+		if pr.Contains(port) {
+			found = true
+		}
+	}
+	return found != (not == Enabled), nil
+}
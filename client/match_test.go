@@ -0,0 +1,94 @@
+package client_test
+
+import (
+	"net/netip"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func TestFirewallRuleMatches(t *testing.T) {
+	packet := bboxclient.Packet{
+		SrcIP:    netip.MustParseAddr("10.0.0.5"),
+		DstIP:    netip.MustParseAddr("192.168.1.20"),
+		SrcPort:  50000,
+		DstPort:  443,
+		Protocol: bboxclient.ProtocolTCP,
+	}
+
+	tests := []struct {
+		name string
+		rule bboxclient.FirewallRule
+		want bool
+	}{
+		{"any", bboxclient.FirewallRule{}, true},
+		{"source network", bboxclient.FirewallRule{SrcIP: "10.0.0.0/8"}, true},
+		{"negated source network", bboxclient.FirewallRule{SrcIP: "10.0.0.0/8", SrcIPNot: bboxclient.Enabled}, false},
+		{"destination range", bboxclient.FirewallRule{DstIP: "192.168.1.10-192.168.1.30"}, true},
+		{"other destination", bboxclient.FirewallRule{DstIP: "192.168.1.21"}, false},
+		{"negated other destination", bboxclient.FirewallRule{DstIP: "192.168.1.21", DstIPNot: bboxclient.Enabled}, true},
+		{"port list", bboxclient.FirewallRule{DstPorts: "80,443"}, true},
+		{"port range", bboxclient.FirewallRule{DstPorts: "1000:2000"}, false},
+		{"negated port", bboxclient.FirewallRule{DstPorts: "22", DstPortNot: bboxclient.Enabled}, true},
+		{"source port", bboxclient.FirewallRule{SrcPorts: "53"}, false},
+		{"udp only", bboxclient.FirewallRule{Protocols: bboxclient.ProtocolUDP}, false},
+		{"protocol list", bboxclient.FirewallRule{Protocols: bboxclient.ProtocolAny}, true},
+		{"IPv6 only", bboxclient.FirewallRule{IPProtocol: bboxclient.IPProtocolIPv6}, false},
+		{"both versions", bboxclient.FirewallRule{IPProtocol: bboxclient.IPProtocolBoth}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.Matches(packet)
+			if err != nil {
+				t.Fatalf("Matches: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Matches() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirewallRuleMatchesUnknownFields(t *testing.T) {
+	packet := bboxclient.Packet{DstIP: netip.MustParseAddr("192.168.1.20")}
+
+	rule := bboxclient.FirewallRule{SrcIP: "10.0.0.0/8"}
+	if ok, _ := rule.Matches(packet); ok {
+		t.Error("rule on the source address matched a packet without source")
+	}
+	rule = bboxclient.FirewallRule{Protocols: bboxclient.ProtocolTCP}
+	if ok, _ := rule.Matches(packet); ok {
+		t.Error("tcp rule matched a packet without protocol")
+	}
+}
+
+func TestEvaluateFirewall(t *testing.T) {
+	rules := []bboxclient.FirewallRule{
+		{ID: 1, Order: 3, Enable: bboxclient.Enabled, Action: bboxclient.ActionDeny},
+		{ID: 2, Order: 1, Enable: bboxclient.Disabled, Action: bboxclient.ActionDeny, DstPorts: "22"},
+		{ID: 3, Order: 2, Enable: bboxclient.Enabled, Action: bboxclient.ActionAllow, DstPorts: "22"},
+	}
+	packet := bboxclient.Packet{DstPort: 22, Protocol: bboxclient.ProtocolTCP}
+
+	rule, ok, err := bboxclient.EvaluateFirewall(rules, packet)
+	if err != nil || !ok {
+		t.Fatalf("EvaluateFirewall = %v, %t, %v", rule, ok, err)
+	}
+	if rule.ID != 3 {
+		t.Errorf("first match = rule %d, want 3", rule.ID)
+	}
+
+	matches, err := bboxclient.FirewallMatches(rules, packet)
+	if err != nil || len(matches) != 2 || matches[1].ID != 1 {
+		t.Errorf("FirewallMatches = %+v, %v", matches, err)
+	}
+
+	if _, ok, _ := bboxclient.EvaluateFirewall(rules[1:], bboxclient.Packet{DstPort: 80}); ok {
+		t.Error("a packet matched no enabled rule but EvaluateFirewall found one")
+	}
+
+	bad := []bboxclient.FirewallRule{{ID: 9, Enable: bboxclient.Enabled, SrcIP: "not-an-ip"}}
+	if _, _, err := bboxclient.EvaluateFirewall(bad, bboxclient.Packet{SrcIP: netip.MustParseAddr("10.0.0.1")}); err == nil {
+		t.Error("EvaluateFirewall accepted a rule with an invalid address")
+	}
+}
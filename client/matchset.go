@@ -0,0 +1,255 @@
+package client
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// point is an ordered value spans are made of: IP addresses or ports
+type point[T any] interface {
+	comparable
+	Less(T) bool
+	Next() T
+	Prev() T
+}
+
+// span is an inclusive range of points
+type span[T point[T]] struct {
+	lo, hi T
+}
+
+// spanSet is a sorted list of disjoint, non-adjacent spans
+type spanSet[T point[T]] []span[T]
+
+// newSpanSet sorts and merges spans
+func newSpanSet[T point[T]](spans []span[T]) spanSet[T] {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].lo.Less(spans[j].lo) })
+	var set spanSet[T]
+	for _, s := range spans {
+		if n := len(set); n > 0 {
+			last := &set[n-1]
+			if !last.hi.Less(s.lo) || last.hi.Next() == s.lo {
+				if last.hi.Less(s.hi) {
+					last.hi = s.hi
+				}
+				continue
+			}
+		}
+		set = append(set, s)
+	}
+	return set
+}
+
+// complement returns the points of [lo, hi] not in set
+func (set spanSet[T]) complement(lo, hi T) spanSet[T] {
+	var out spanSet[T]
+	next := lo
+	for _, s := range set {
+		if next.Less(s.lo) {
+			out = append(out, span[T]{next, s.lo.Prev()})
+		}
+		if s.hi == hi {
+			return out
+		}
+		next = s.hi.Next()
+	}
+	return append(out, span[T]{next, hi})
+}
+
+// contains reports whether every point of other is in set
+func (set spanSet[T]) contains(other spanSet[T]) bool {
+	for _, o := range other {
+		inside := false
+		for _, s := range set {
+			if !o.lo.Less(s.lo) && !s.hi.Less(o.hi) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return false
+		}
+	}
+	return true
+}
+
+// intersects reports whether set and other share a point
+func (set spanSet[T]) intersects(other spanSet[T]) bool {
+	for _, a := range set {
+		for _, b := range other {
+			if !b.hi.Less(a.lo) && !a.hi.Less(b.lo) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// port is a port number usable in a spanSet
+type port int
+
+func (p port) Less(o port) bool { return p < o }
+func (p port) Next() port       { return p + 1 }
+func (p port) Prev() port       { return p - 1 }
+
+var (
+	allIPv4 = span[netip.Addr]{netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("255.255.255.255")}
+	allIPv6 = span[netip.Addr]{netip.IPv6Unspecified(), netip.MustParseAddr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")}
+	allPort = span[port]{1, 65535}
+)
+
+// familyMatch is the traffic of one IP version a rule matches
+type familyMatch struct {
+	src, dst spanSet[netip.Addr]
+}
+
+// matchSet is every packet a rule matches, as used by the linter
+type matchSet struct {
+	families  map[bool]familyMatch // keyed by "is IPv6"
+	protocols map[Protocol]bool
+	srcPorts  spanSet[port]
+	dstPorts  spanSet[port]
+}
+
+// newMatchSet expands the match fields of a rule
+func newMatchSet(r *FirewallRule) (matchSet, error) {
+	m := matchSet{
+		families:  make(map[bool]familyMatch),
+		protocols: make(map[Protocol]bool),
+	}
+
+	for _, ipv6 := range []bool{false, true} {
+		if !r.coversIPVersion(ipv6) {
+			continue
+		}
+		src, err := addressSet(r.SrcIP, r.SrcIPNot, ipv6)
+		if err != nil {
+			return m, err
+		}
+		dst, err := addressSet(r.DstIP, r.DstIPNot, ipv6)
+		if err != nil {
+			return m, err
+		}
+		m.families[ipv6] = familyMatch{src: src, dst: dst}
+	}
+
+	for _, p := range []Protocol{ProtocolTCP, ProtocolUDP} {
+		if r.coversProtocol(p) {
+			m.protocols[p] = true
+		}
+	}
+
+	var err error
+	if m.srcPorts, err = portSet(r.SrcPorts, r.SrcPortNot); err != nil {
+		return m, err
+	}
+	if m.dstPorts, err = portSet(r.DstPorts, r.DstPortNot); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// contains reports whether every packet of other is also in m
+func (m matchSet) contains(other matchSet) bool {
+	if other.empty() {
+		return true
+	}
+	for p := range other.protocols {
+		if !m.protocols[p] {
+			return false
+		}
+	}
+	for ipv6, of := range other.families {
+		if len(of.src) == 0 || len(of.dst) == 0 {
+			continue
+		}
+		f, ok := m.families[ipv6]
+		if !ok || !f.src.contains(of.src) || !f.dst.contains(of.dst) {
+			return false
+		}
+	}
+	return m.srcPorts.contains(other.srcPorts) && m.dstPorts.contains(other.dstPorts)
+}
+
+// intersects reports whether a packet exists in both m and other
+func (m matchSet) intersects(other matchSet) bool {
+	protocol := false
+	for p := range other.protocols {
+		protocol = protocol || m.protocols[p]
+	}
+	if !protocol || !m.srcPorts.intersects(other.srcPorts) || !m.dstPorts.intersects(other.dstPorts) {
+		return false
+	}
+	for ipv6, of := range other.families {
+		if f, ok := m.families[ipv6]; ok && f.src.intersects(of.src) && f.dst.intersects(of.dst) {
+			return true
+		}
+	}
+	return false
+}
+
+// empty reports whether the rule can never match
+func (m matchSet) empty() bool {
+	if len(m.protocols) == 0 || len(m.srcPorts) == 0 || len(m.dstPorts) == 0 {
+		return true
+	}
+	for _, f := range m.families {
+		if len(f.src) > 0 && len(f.dst) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// equal reports whether m and other match exactly the same packets
+func (m matchSet) equal(other matchSet) bool {
+	return m.contains(other) && other.contains(m)
+}
+
+// addressSet expands a comma-separated address field for one IP version
+func addressSet(spec StringOrInt, not EnableState, ipv6 bool) (spanSet[netip.Addr], error) {
+	all := allIPv4
+	if ipv6 {
+		all = allIPv6
+	}
+	if spec == "" {
+		return spanSet[netip.Addr]{all}, nil
+	}
+
+	matches, err := ParseAddressList(spec.String())
+	if err != nil {
+		return nil, err
+	}
+	var spans []span[netip.Addr]
+	for _, m := range matches {
+		if m.Is4() != ipv6 {
+			spans = append(spans, span[netip.Addr]{m.First.Unmap(), m.Last.Unmap()})
+		}
+	}
+	set := newSpanSet(spans)
+	if not == Enabled {
+		set = set.complement(all.lo, all.hi)
+	}
+	return set, nil
+}
+
+// portSet expands a port list field
+func portSet(spec StringOrInt, not EnableState) (spanSet[port], error) {
+	if spec == "" {
+		return spanSet[port]{allPort}, nil
+	}
+
+	ranges, err := ParsePortList(spec.String())
+	if err != nil {
+		return nil, err
+	}
+	var spans []span[port]
+	for _, pr := range ranges {
+		spans = append(spans, span[port]{port(pr.First), port(pr.Last)})
+	}
+	set := newSpanSet(spans)
+	if not == Enabled {
+		set = set.complement(allPort.lo, allPort.hi)
+	}
+	return set, nil
+}
@@ -1,9 +1,13 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -14,53 +18,210 @@ type NatInterface struct {
 
 // GetNatRules retrieves all NAT rules from the Bbox device.
 func (ni *NatInterface) GetNatRules() ([]NatRule, error) {
+	return ni.GetNatRulesContext(context.Background())
+}
+
+// GetNatRulesContext is like GetNatRules but bound to ctx.
+func (ni *NatInterface) GetNatRulesContext(ctx context.Context) ([]NatRule, error) {
 	var result []NatResponse
-	r, err := ni.Client.Get("/nat/rules")
+	r, err := ni.Client.GetContext(ctx, "/nat/rules")
 	if err != nil {
 		return nil, err
 	}
 	defer r.Body.Close()
 
+	if err := ni.Client.checkResponse(r, http.StatusOK); err != nil {
+		return nil, err
+	}
+
 	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
 		return nil, err
 	}
+	if len(result) == 0 {
+		return nil, errors.New("no NAT rules in response")
+	}
 	return result[0].Nat.Rules, nil
 }
 
 // GetNatRuleByID retrieves a specific NAT rule by its ID.
 func (ni *NatInterface) GetNatRuleByID(ruleID int) (NatRule, error) {
-	var result NatRule
-	r, err := ni.Client.Get("/nat/rules/" + string(rune(ruleID)))
+	return ni.GetNatRuleByIDContext(context.Background(), ruleID)
+}
+
+// GetNatRuleByIDContext is like GetNatRuleByID but bound to ctx.
+func (ni *NatInterface) GetNatRuleByIDContext(ctx context.Context, ruleID int) (NatRule, error) {
+	rules, err := ni.GetNatRulesContext(ctx)
 	if err != nil {
-		return result, err
+		return NatRule{}, err
 	}
-	defer r.Body.Close()
 
-	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
-		return result, err
+	for _, rule := range rules {
+		if rule.ID == ruleID {
+			return rule, nil
+		}
 	}
-	return result, nil
+	return NatRule{}, ErrNatRuleNotFound
+}
+
+// AddNatRule creates a new NAT rule (port forward).
+func (ni *NatInterface) AddNatRule(rule NatRule) error {
+	return ni.AddNatRuleContext(context.Background(), rule)
+}
+
+// AddNatRuleContext is like AddNatRule but bound to ctx.
+func (ni *NatInterface) AddNatRuleContext(ctx context.Context, rule NatRule) error {
+	return ni.journalNat(ctx, ChangeAdd, rule, func() error {
+		return ni.addNatRule(ctx, rule)
+	})
+}
+
+func (ni *NatInterface) addNatRule(ctx context.Context, rule NatRule) error {
+	r, err := ni.Client.newTokenRequest(ctx, "POST", "/nat/rules", strings.NewReader(rule.RuleAsString()))
+	if err != nil {
+		return err
+	}
+
+	resp, err := ni.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := ni.Client.checkResponse(resp, http.StatusCreated); err != nil {
+		return fmt.Errorf("failed to add NAT rule: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateNatRule replaces the NAT rule identified by rule.ID.
+func (ni *NatInterface) UpdateNatRule(rule NatRule) error {
+	return ni.UpdateNatRuleContext(context.Background(), rule)
+}
+
+// UpdateNatRuleContext is like UpdateNatRule but bound to ctx.
+func (ni *NatInterface) UpdateNatRuleContext(ctx context.Context, rule NatRule) error {
+	return ni.journalNat(ctx, ChangeUpdate, rule, func() error {
+		return ni.updateNatRule(ctx, rule)
+	})
+}
+
+func (ni *NatInterface) updateNatRule(ctx context.Context, rule NatRule) error {
+	path := fmt.Sprintf("/nat/rules/%d", rule.ID)
+	r, err := ni.Client.newTokenRequest(ctx, "PUT", path, strings.NewReader(rule.RuleAsString()))
+	if err != nil {
+		return err
+	}
+
+	resp, err := ni.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := ni.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to update NAT rule: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteNatRule removes a NAT rule by its ID.
+func (ni *NatInterface) DeleteNatRule(ruleID string) error {
+	return ni.DeleteNatRuleContext(context.Background(), ruleID)
+}
+
+// DeleteNatRuleContext is like DeleteNatRule but bound to ctx.
+func (ni *NatInterface) DeleteNatRuleContext(ctx context.Context, ruleID string) error {
+	id, err := strconv.Atoi(ruleID)
+	if err != nil {
+		return ni.deleteNatRule(ctx, ruleID)
+	}
+	return ni.journalNat(ctx, ChangeDelete, NatRule{ID: id}, func() error {
+		return ni.deleteNatRule(ctx, ruleID)
+	})
+}
+
+func (ni *NatInterface) deleteNatRule(ctx context.Context, ruleID string) error {
+	r, err := ni.Client.newTokenRequest(ctx, "DELETE", "/nat/rules/"+ruleID, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ni.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := ni.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to delete NAT rule: %w", err)
+	}
+
+	return nil
 }
 
 // changeNatRuleState enables or disables a NAT rule based on the provided state.
-func (ni *NatInterface) changeNatRuleState(ruleID string, enable EnableState) error {
-	path := "/nat/rules/" + ruleID
+func (ni *NatInterface) changeNatRuleState(ctx context.Context, ruleID string, enable EnableState) error {
 	data := fmt.Sprintf("enable=%d", enable)
-	req, err := http.NewRequest("PUT", path, strings.NewReader(data))
+	r, err := ni.Client.newTokenRequest(ctx, "PUT", "/nat/rules/"+ruleID, strings.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := ni.Client.Do(r)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	_, err = ni.Client.Do(req)
-	return err
+	defer resp.Body.Close()
+
+	if err := ni.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to change NAT rule state: %w", err)
+	}
+
+	return nil
+}
+
+func (ni *NatInterface) journaledState(ctx context.Context, op ChangeOp, ruleID string, enable EnableState) error {
+	id, err := strconv.Atoi(ruleID)
+	if err != nil {
+		return ni.changeNatRuleState(ctx, ruleID, enable)
+	}
+	return ni.journalNat(ctx, op, NatRule{ID: id}, func() error {
+		return ni.changeNatRuleState(ctx, ruleID, enable)
+	})
 }
 
 // EnableNatRule enables a NAT rule by its ID.
 func (ni *NatInterface) EnableNatRule(ruleID string) error {
-	return ni.changeNatRuleState(ruleID, Enabled)
+	return ni.EnableNatRuleContext(context.Background(), ruleID)
+}
+
+// EnableNatRuleContext is like EnableNatRule but bound to ctx.
+func (ni *NatInterface) EnableNatRuleContext(ctx context.Context, ruleID string) error {
+	return ni.journaledState(ctx, ChangeEnable, ruleID, Enabled)
 }
 
 // DisableNatRule disables a NAT rule by its ID.
 func (ni *NatInterface) DisableNatRule(ruleID string) error {
-	return ni.changeNatRuleState(ruleID, Disabled)
+	return ni.DisableNatRuleContext(context.Background(), ruleID)
+}
+
+// DisableNatRuleContext is like DisableNatRule but bound to ctx.
+func (ni *NatInterface) DisableNatRuleContext(ctx context.Context, ruleID string) error {
+	return ni.journaledState(ctx, ChangeDisable, ruleID, Disabled)
+}
+
+// RuleAsString converts the NAT rule to URL-encoded form data
+// for API requests
+func (r *NatRule) RuleAsString() string {
+	v := url.Values{}
+	v.Set("enable", fmt.Sprintf("%d", r.Enable))
+	v.Set("description", r.Description)
+	v.Set("protocol", string(r.Protocol))
+	v.Set("externalip", r.SrcIP.String())
+	v.Set("externalport", r.SrcPorts.String())
+	v.Set("internalip", r.TargetIP.String())
+	v.Set("internalport", r.TargetPorts.String())
+	return v.Encode()
 }
@@ -0,0 +1,154 @@
+package client_test
+
+import (
+	"errors"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func TestGetNatRules(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetNatRules([]bboxclient.NatRule{
+		{ID: 1, Description: "web", TargetIP: "192.168.1.10", TargetPorts: "80", SrcPorts: "8080"},
+	})
+
+	rules, err := client.Nat().GetNatRules()
+	if err != nil {
+		t.Fatalf("GetNatRules: %v", err)
+	}
+	if len(rules) != 1 || rules[0].TargetIP != "192.168.1.10" || rules[0].SrcPorts != "8080" {
+		t.Errorf("rules = %+v", rules)
+	}
+}
+
+func TestGetNatRuleByID(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetNatRules([]bboxclient.NatRule{{ID: 1}, {ID: 12, Description: "twelve"}})
+
+	rule, err := client.Nat().GetNatRuleByID(12)
+	if err != nil {
+		t.Fatalf("GetNatRuleByID: %v", err)
+	}
+	if rule.Description != "twelve" {
+		t.Errorf("rule = %+v", rule)
+	}
+
+	if _, err := client.Nat().GetNatRuleByID(99); !errors.Is(err, bboxclient.ErrNatRuleNotFound) {
+		t.Errorf("unknown ID: err = %v, want ErrNatRuleNotFound", err)
+	}
+}
+
+func TestAddNatRule(t *testing.T) {
+	server, client := newTestClient(t)
+
+	rule := bboxclient.NatRule{
+		Enable:      bboxclient.Enabled,
+		Description: "game server",
+		Protocol:    bboxclient.ProtocolUDP,
+		SrcPorts:    "27015",
+		TargetIP:    "192.168.1.30",
+		TargetPorts: "27015",
+	}
+	if err := client.Nat().AddNatRule(rule); err != nil {
+		t.Fatalf("AddNatRule: %v", err)
+	}
+
+	stored := server.NatRules()
+	if len(stored) != 1 {
+		t.Fatalf("server has %d rules, want 1", len(stored))
+	}
+	rule.ID = stored[0].ID
+	if stored[0] != rule {
+		t.Errorf("stored rule = %+v, want %+v", stored[0], rule)
+	}
+}
+
+func TestUpdateNatRule(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetNatRules([]bboxclient.NatRule{{ID: 5, Description: "old", TargetPorts: "22"}})
+
+	rule := server.NatRules()[0]
+	rule.Description = "new"
+	rule.TargetPorts = "2222"
+	if err := client.Nat().UpdateNatRule(rule); err != nil {
+		t.Fatalf("UpdateNatRule: %v", err)
+	}
+
+	if got := server.NatRules()[0]; got != rule {
+		t.Errorf("stored rule = %+v, want %+v", got, rule)
+	}
+}
+
+func TestDeleteNatRule(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetNatRules([]bboxclient.NatRule{{ID: 1}, {ID: 2}})
+
+	if err := client.Nat().DeleteNatRule("2"); err != nil {
+		t.Fatalf("DeleteNatRule: %v", err)
+	}
+	if rules := server.NatRules(); len(rules) != 1 || rules[0].ID != 1 {
+		t.Errorf("remaining rules = %+v", rules)
+	}
+	if err := client.Nat().DeleteNatRule("2"); err == nil {
+		t.Error("deleting a missing rule succeeded")
+	}
+}
+
+func TestEnableDisableNatRule(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetNatRules([]bboxclient.NatRule{{ID: 3, Description: "keep"}})
+
+	nat := client.Nat()
+	if err := nat.EnableNatRule("3"); err != nil {
+		t.Fatalf("EnableNatRule: %v", err)
+	}
+	if got := server.NatRules()[0]; got.Enable != bboxclient.Enabled || got.Description != "keep" {
+		t.Errorf("after enable: %+v", got)
+	}
+
+	if err := nat.DisableNatRule("3"); err != nil {
+		t.Fatalf("DisableNatRule: %v", err)
+	}
+	if got := server.NatRules()[0]; got.Enable != bboxclient.Disabled {
+		t.Errorf("after disable: %+v", got)
+	}
+}
+
+func TestNatRuleValidate(t *testing.T) {
+	valid := bboxclient.NatRule{
+		Enable:      bboxclient.Enabled,
+		Description: "web",
+		Protocol:    bboxclient.ProtocolTCP,
+		SrcPorts:    "8080-8090",
+		TargetIP:    "192.168.1.20",
+		TargetPorts: "80-90",
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid NAT rule: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		edit  func(r *bboxclient.NatRule)
+		field string
+	}{
+		{"reversed external range", func(r *bboxclient.NatRule) { r.SrcPorts = "8090-8080" }, "externalport"},
+		{"external port out of range", func(r *bboxclient.NatRule) { r.SrcPorts = "70000" }, "externalport"},
+		{"IPv6 external address", func(r *bboxclient.NatRule) { r.SrcIP = "2001:db8::1" }, "externalip"},
+		{"missing internal address", func(r *bboxclient.NatRule) { r.TargetIP = "" }, "internalip"},
+		{"internal network", func(r *bboxclient.NatRule) { r.TargetIP = "192.168.1.0/24" }, "internalip"},
+		{"missing internal port", func(r *bboxclient.NatRule) { r.TargetPorts = "" }, "internalport"},
+		{"bad protocol", func(r *bboxclient.NatRule) { r.Protocol = "icmp" }, "protocol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid
+			tt.edit(&rule)
+			var errs bboxclient.ValidationErrors
+			if !errors.As(rule.Validate(), &errs) || len(errs) != 1 || errs[0].Field != tt.field {
+				t.Errorf("Validate() = %v, want one error on %s", errs, tt.field)
+			}
+		})
+	}
+}
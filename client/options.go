@@ -0,0 +1,115 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds every request of a client created without the
+// WithTimeout option
+const DefaultTimeout = 30 * time.Second
+
+// Option configures a BboxClient created by NewClient
+type Option func(*BboxClient)
+
+// WithTimeout sets the time limit of each request, including reading the
+// response body. Zero disables the limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(bc *BboxClient) {
+		bc.Client.Timeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to reach the router, e.g. to
+// trust its self-signed certificate. It applies to the default transport and
+// to an *http.Transport given to WithTransport; NewClient fails when it is
+// combined with any other transport.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(bc *BboxClient) {
+		bc.tlsConfig = config
+	}
+}
+
+// WithTransport replaces http.DefaultTransport as the transport that sends
+// the requests, e.g. with a stub in tests
+func WithTransport(transport http.RoundTripper) Option {
+	return func(bc *BboxClient) {
+		bc.transport = transport
+	}
+}
+
+// Middleware wraps the transport of a client, to add headers, log or count
+// requests, inject faults...
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithMiddleware wraps the transport with the given middleware. The first one
+// is the outermost: it sees requests first and responses last. The option
+// can be repeated, later middleware being nested inside earlier ones.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(bc *BboxClient) {
+		bc.middleware = append(bc.middleware, middleware...)
+	}
+}
+
+// BeforeHook is called before each request is sent, including the login,
+// token and replayed requests. It may modify the request; an error aborts it
+// and is returned to the caller.
+type BeforeHook func(req *http.Request) error
+
+// AfterHook is called once a request is done, with either its response or
+// its error. It must not consume the response body.
+type AfterHook func(req *http.Request, resp *http.Response, err error)
+
+// WithBeforeRequest adds hooks called in order before every request
+func WithBeforeRequest(hooks ...BeforeHook) Option {
+	return func(bc *BboxClient) {
+		bc.before = append(bc.before, hooks...)
+	}
+}
+
+// WithAfterResponse adds hooks called in order after every request
+func WithAfterResponse(hooks ...AfterHook) Option {
+	return func(bc *BboxClient) {
+		bc.after = append(bc.after, hooks...)
+	}
+}
+
+// buildTransport assembles the transport of the client from the options
+func (bc *BboxClient) buildTransport() (http.RoundTripper, error) {
+	transport := bc.transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if bc.tlsConfig != nil {
+		t, ok := transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("WithTLSConfig needs an *http.Transport, got %T", transport)
+		}
+		t = t.Clone()
+		t.TLSClientConfig = bc.tlsConfig
+		transport = t
+	}
+	for i := len(bc.middleware) - 1; i >= 0; i-- {
+		transport = bc.middleware[i](transport)
+	}
+	return transport, nil
+}
+
+// WithJournal records every change made to firewall and NAT rules, with the
+// rule before and after it, so that it can be undone with UndoChange. Each
+// change costs extra requests to read the rules.
+func WithJournal(journal Journal) Option {
+	return func(bc *BboxClient) {
+		bc.journal = journal
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SortFirewallRules sorts rules by Order, the precedence the router applies
+// them in. Rules sharing an order are sorted by ID.
+func SortFirewallRules(rules []FirewallRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Order != rules[j].Order {
+			return rules[i].Order < rules[j].Order
+		}
+		return rules[i].ID < rules[j].ID
+	})
+}
+
+// PlanFirewallMove returns the updates that move the rule with the given ID
+// to position (1-based) and renumber every rule from 1. Positions past the
+// end move the rule last.
+func PlanFirewallMove(rules []FirewallRule, id, position int) (FirewallPlan, error) {
+	if position < 1 {
+		return FirewallPlan{}, fmt.Errorf("invalid position %d", position)
+	}
+	rule, rest, err := takeRule(rules, id)
+	if err != nil {
+		return FirewallPlan{}, err
+	}
+	if position > len(rest)+1 {
+		position = len(rest) + 1
+	}
+	return planOrder(insertRule(rest, rule, position-1)), nil
+}
+
+// PlanFirewallMoveRelative is like PlanFirewallMove but places the rule
+// right before target, or right after it when after is set
+func PlanFirewallMoveRelative(rules []FirewallRule, id, target int, after bool) (FirewallPlan, error) {
+	if id == target {
+		return FirewallPlan{}, fmt.Errorf("cannot move rule %d relative to itself", id)
+	}
+	rule, rest, err := takeRule(rules, id)
+	if err != nil {
+		return FirewallPlan{}, err
+	}
+
+	index := -1
+	for i := range rest {
+		if rest[i].ID == target {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return FirewallPlan{}, fmt.Errorf("rule %d: %w", target, ErrFirewallRuleNotFound)
+	}
+	if after {
+		index++
+	}
+	return planOrder(insertRule(rest, rule, index)), nil
+}
+
+// PlanFirewallReorder returns the updates that put the rules listed in ids
+// first, in that order, followed by the other rules in their current order,
+// and renumber everything from 1. With no ids it only closes gaps and
+// resolves rules sharing an order.
+func PlanFirewallReorder(rules []FirewallRule, ids []int) (FirewallPlan, error) {
+	rest := sortedCopy(rules)
+	var ordered []FirewallRule
+	for _, id := range ids {
+		var rule FirewallRule
+		var err error
+		rule, rest, err = takeRule(rest, id)
+		if err != nil {
+			return FirewallPlan{}, err
+		}
+		ordered = append(ordered, rule)
+	}
+	return planOrder(append(ordered, rest...)), nil
+}
+
+// takeRule returns the rule with the given ID and the other rules sorted by
+// order
+func takeRule(rules []FirewallRule, id int) (FirewallRule, []FirewallRule, error) {
+	sorted := sortedCopy(rules)
+	for i := range sorted {
+		if sorted[i].ID == id {
+			rule := sorted[i]
+			return rule, append(sorted[:i], sorted[i+1:]...), nil
+		}
+	}
+	return FirewallRule{}, nil, fmt.Errorf("rule %d: %w", id, ErrFirewallRuleNotFound)
+}
+
+func sortedCopy(rules []FirewallRule) []FirewallRule {
+	sorted := append([]FirewallRule(nil), rules...)
+	SortFirewallRules(sorted)
+	return sorted
+}
+
+func insertRule(rules []FirewallRule, rule FirewallRule, index int) []FirewallRule {
+	rules = append(rules, FirewallRule{})
+	copy(rules[index+1:], rules[index:])
+	rules[index] = rule
+	return rules
+}
+
+// planOrder numbers rules from 1 in the given sequence and returns an update
+// for each rule whose order changes
+func planOrder(rules []FirewallRule) FirewallPlan {
+	var plan FirewallPlan
+	for i, rule := range rules {
+		if rule.Order == i+1 {
+			continue
+		}
+		change := FieldChange{Field: "order", Old: fmt.Sprint(rule.Order), New: fmt.Sprint(i + 1)}
+		rule.Order = i + 1
+		plan.Steps = append(plan.Steps, FirewallPlanStep{
+			Action:  PlanUpdate,
+			Rule:    rule,
+			Changes: []FieldChange{change},
+		})
+	}
+	return plan
+}
@@ -0,0 +1,139 @@
+package client_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+// orderRules returns rules with the given IDs, numbered in that order
+func orderRules(ids ...int) []bboxclient.FirewallRule {
+	var rules []bboxclient.FirewallRule
+	for i, id := range ids {
+		rules = append(rules, bboxclient.FirewallRule{ID: id, Order: i + 1})
+	}
+	return rules
+}
+
+// planOrders maps the ID of every updated rule to its new order
+func planOrders(plan bboxclient.FirewallPlan) map[int]int {
+	orders := make(map[int]int)
+	for _, step := range plan.Steps {
+		orders[step.Rule.ID] = step.Rule.Order
+	}
+	return orders
+}
+
+func TestSortFirewallRules(t *testing.T) {
+	rules := []bboxclient.FirewallRule{{ID: 4, Order: 2}, {ID: 9, Order: 1}, {ID: 2, Order: 2}}
+	bboxclient.SortFirewallRules(rules)
+
+	var ids []int
+	for _, r := range rules {
+		ids = append(ids, r.ID)
+	}
+	if want := []int{9, 2, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("sorted IDs = %v, want %v", ids, want)
+	}
+}
+
+func TestPlanFirewallMove(t *testing.T) {
+	rules := orderRules(10, 20, 30, 40)
+
+	tests := []struct {
+		name string
+		plan func() (bboxclient.FirewallPlan, error)
+		want map[int]int
+	}{
+		{"to first", func() (bboxclient.FirewallPlan, error) {
+			return bboxclient.PlanFirewallMove(rules, 30, 1)
+		}, map[int]int{30: 1, 10: 2, 20: 3}},
+		{"past the end", func() (bboxclient.FirewallPlan, error) {
+			return bboxclient.PlanFirewallMove(rules, 10, 99)
+		}, map[int]int{20: 1, 30: 2, 40: 3, 10: 4}},
+		{"before", func() (bboxclient.FirewallPlan, error) {
+			return bboxclient.PlanFirewallMoveRelative(rules, 40, 20, false)
+		}, map[int]int{40: 2, 20: 3, 30: 4}},
+		{"after", func() (bboxclient.FirewallPlan, error) {
+			return bboxclient.PlanFirewallMoveRelative(rules, 10, 30, true)
+		}, map[int]int{20: 1, 30: 2, 10: 3}},
+		{"already there", func() (bboxclient.FirewallPlan, error) {
+			return bboxclient.PlanFirewallMoveRelative(rules, 20, 10, true)
+		}, map[int]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := tt.plan()
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if got := planOrders(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("new orders = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := bboxclient.PlanFirewallMove(rules, 99, 1); !errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		t.Errorf("unknown rule: err = %v, want ErrFirewallRuleNotFound", err)
+	}
+	if _, err := bboxclient.PlanFirewallMoveRelative(rules, 10, 99, false); !errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		t.Errorf("unknown target: err = %v, want ErrFirewallRuleNotFound", err)
+	}
+}
+
+func TestPlanFirewallReorder(t *testing.T) {
+	rules := []bboxclient.FirewallRule{{ID: 1, Order: 5}, {ID: 2, Order: 5}, {ID: 3, Order: 9}}
+
+	plan, err := bboxclient.PlanFirewallReorder(rules, nil)
+	if err != nil {
+		t.Fatalf("PlanFirewallReorder: %v", err)
+	}
+	if got, want := planOrders(plan), map[int]int{1: 1, 2: 2, 3: 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("renumbered orders = %v, want %v", got, want)
+	}
+
+	plan, err = bboxclient.PlanFirewallReorder(rules, []int{3})
+	if err != nil {
+		t.Fatalf("PlanFirewallReorder: %v", err)
+	}
+	if got, want := planOrders(plan), map[int]int{3: 1, 1: 2, 2: 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reordered orders = %v, want %v", got, want)
+	}
+}
+
+func TestApplyFirewallMove(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "a", Order: 1},
+		{ID: 2, Description: "b", Order: 2},
+	})
+
+	fw := client.Firewall()
+	rules, err := fw.GetFirewallRules()
+	if err != nil {
+		t.Fatalf("GetFirewallRules: %v", err)
+	}
+	plan, err := bboxclient.PlanFirewallMove(rules, 2, 1)
+	if err != nil {
+		t.Fatalf("PlanFirewallMove: %v", err)
+	}
+	// Edited on the router meanwhile: only the order must be sent
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "a", Order: 1},
+		{ID: 2, Description: "b", Order: 2, DstPorts: "443"},
+	})
+	if err := fw.ApplyFirewallPlan(plan); err != nil {
+		t.Fatalf("ApplyFirewallPlan: %v", err)
+	}
+
+	for _, r := range server.FirewallRules() {
+		if want := map[int]int{1: 2, 2: 1}[r.ID]; r.Order != want {
+			t.Errorf("rule %d order = %d, want %d", r.ID, r.Order, want)
+		}
+		if r.ID == 2 && r.DstPorts != "443" {
+			t.Errorf("rule 2 dstports = %q, want the concurrent edit kept", r.DstPorts)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PinholeInterface provides methods to manage the IPv6 pinholes of the
+// firewall
+type PinholeInterface struct {
+	Client *BboxClient
+}
+
+// GetPinholeRules retrieves all IPv6 pinholes from the device
+func (pi *PinholeInterface) GetPinholeRules() ([]PinholeRule, error) {
+	return pi.GetPinholeRulesContext(context.Background())
+}
+
+// GetPinholeRulesContext is like GetPinholeRules but bound to ctx
+func (pi *PinholeInterface) GetPinholeRulesContext(ctx context.Context) ([]PinholeRule, error) {
+	resp, err := pi.Client.GetContext(ctx, "/firewall/pinhole")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := pi.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var pinholeResp []PinholeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pinholeResp); err != nil {
+		return nil, err
+	}
+
+	if len(pinholeResp) == 0 {
+		return nil, errors.New("no IPv6 pinholes in response")
+	}
+
+	return pinholeResp[0].Pinhole.Rules, nil
+}
+
+// GetPinholeRuleByID retrieves a specific IPv6 pinhole by its ID
+func (pi *PinholeInterface) GetPinholeRuleByID(ruleID int) (PinholeRule, error) {
+	return pi.GetPinholeRuleByIDContext(context.Background(), ruleID)
+}
+
+// GetPinholeRuleByIDContext is like GetPinholeRuleByID but bound to ctx
+func (pi *PinholeInterface) GetPinholeRuleByIDContext(ctx context.Context, ruleID int) (PinholeRule, error) {
+	rules, err := pi.GetPinholeRulesContext(ctx)
+	if err != nil {
+		return PinholeRule{}, err
+	}
+
+	for _, rule := range rules {
+		if rule.ID == ruleID {
+			return rule, nil
+		}
+	}
+	return PinholeRule{}, ErrPinholeNotFound
+}
+
+// AddPinholeRule creates a new IPv6 pinhole
+func (pi *PinholeInterface) AddPinholeRule(rule PinholeRule) error {
+	return pi.AddPinholeRuleContext(context.Background(), rule)
+}
+
+// AddPinholeRuleContext is like AddPinholeRule but bound to ctx
+func (pi *PinholeInterface) AddPinholeRuleContext(ctx context.Context, rule PinholeRule) error {
+	r, err := pi.Client.newTokenRequest(ctx, "POST", "/firewall/pinhole", strings.NewReader(rule.RuleAsString()))
+	if err != nil {
+		return err
+	}
+
+	resp, err := pi.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := pi.Client.checkResponse(resp, http.StatusCreated); err != nil {
+		return fmt.Errorf("failed to add pinhole: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePinholeRule replaces the IPv6 pinhole identified by rule.ID
+func (pi *PinholeInterface) UpdatePinholeRule(rule PinholeRule) error {
+	return pi.UpdatePinholeRuleContext(context.Background(), rule)
+}
+
+// UpdatePinholeRuleContext is like UpdatePinholeRule but bound to ctx
+func (pi *PinholeInterface) UpdatePinholeRuleContext(ctx context.Context, rule PinholeRule) error {
+	path := fmt.Sprintf("/firewall/pinhole/%d", rule.ID)
+	r, err := pi.Client.newTokenRequest(ctx, "PUT", path, strings.NewReader(rule.RuleAsString()))
+	if err != nil {
+		return err
+	}
+
+	resp, err := pi.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := pi.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to update pinhole: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePinholeRule removes an IPv6 pinhole by its ID
+func (pi *PinholeInterface) DeletePinholeRule(ruleID string) error {
+	return pi.DeletePinholeRuleContext(context.Background(), ruleID)
+}
+
+// DeletePinholeRuleContext is like DeletePinholeRule but bound to ctx
+func (pi *PinholeInterface) DeletePinholeRuleContext(ctx context.Context, ruleID string) error {
+	r, err := pi.Client.newTokenRequest(ctx, "DELETE", "/firewall/pinhole/"+ruleID, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := pi.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := pi.Client.checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to delete pinhole: %w", err)
+	}
+
+	return nil
+}
+
+// RuleAsString converts the pinhole to URL-encoded form data
+// for API requests
+func (r *PinholeRule) RuleAsString() string {
+	v := url.Values{}
+	v.Set("enable", fmt.Sprintf("%d", r.Enable))
+	v.Set("description", r.Description)
+	v.Set("srcipnot", fmt.Sprintf("%d", r.SrcIPNot))
+	v.Set("srcip", r.SrcIP.String())
+	v.Set("srcportnot", fmt.Sprintf("%d", r.SrcPortNot))
+	v.Set("srcports", r.SrcPorts.String())
+	v.Set("dstip", r.DstIP.String())
+	v.Set("dstportnot", fmt.Sprintf("%d", r.DstPortNot))
+	v.Set("dstports", r.DstPorts.String())
+	v.Set("protocols", string(r.Protocols))
+	return v.Encode()
+}
@@ -0,0 +1,105 @@
+package client_test
+
+import (
+	"errors"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func TestGetPinholeRules(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetPinholeRules([]bboxclient.PinholeRule{
+		{ID: 1, Description: "nas", DstIP: "2001:db8::10", DstPorts: "443"},
+	})
+
+	rules, err := client.Pinhole().GetPinholeRules()
+	if err != nil {
+		t.Fatalf("GetPinholeRules: %v", err)
+	}
+	if len(rules) != 1 || rules[0].DstIP != "2001:db8::10" || rules[0].DstPorts != "443" {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	if _, err := client.Pinhole().GetPinholeRuleByID(99); !errors.Is(err, bboxclient.ErrPinholeNotFound) {
+		t.Errorf("unknown ID: err = %v, want ErrPinholeNotFound", err)
+	}
+}
+
+func TestAddUpdateDeletePinholeRule(t *testing.T) {
+	server, client := newTestClient(t)
+
+	rule := bboxclient.PinholeRule{
+		Enable:      bboxclient.Enabled,
+		Description: "ssh",
+		SrcIP:       "2001:db8:1::/48",
+		DstIP:       "2001:db8::20",
+		DstPorts:    "22",
+		Protocols:   bboxclient.ProtocolTCP,
+	}
+	if err := client.Pinhole().AddPinholeRule(rule); err != nil {
+		t.Fatalf("AddPinholeRule: %v", err)
+	}
+
+	stored := server.PinholeRules()
+	if len(stored) != 1 {
+		t.Fatalf("server has %d pinholes, want 1", len(stored))
+	}
+	rule.ID = stored[0].ID
+	if stored[0] != rule {
+		t.Errorf("stored pinhole = %+v, want %+v", stored[0], rule)
+	}
+
+	rule.DstPorts = "2222"
+	if err := client.Pinhole().UpdatePinholeRule(rule); err != nil {
+		t.Fatalf("UpdatePinholeRule: %v", err)
+	}
+	if got := server.PinholeRules()[0]; got != rule {
+		t.Errorf("updated pinhole = %+v, want %+v", got, rule)
+	}
+
+	if err := client.Pinhole().DeletePinholeRule("99"); !errors.Is(err, bboxclient.ErrPinholeNotFound) {
+		t.Errorf("delete unknown: err = %v, want ErrPinholeNotFound", err)
+	}
+	if err := client.Pinhole().DeletePinholeRule("1"); err != nil {
+		t.Fatalf("DeletePinholeRule: %v", err)
+	}
+	if n := len(server.PinholeRules()); n != 0 {
+		t.Errorf("server has %d pinholes after delete, want 0", n)
+	}
+}
+
+func TestPinholeRuleValidate(t *testing.T) {
+	valid := bboxclient.PinholeRule{
+		Enable:      bboxclient.Enabled,
+		Description: "web",
+		DstIP:       "2001:db8::/64",
+		DstPorts:    "80,443",
+		Protocols:   bboxclient.ProtocolTCP,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid pinhole: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		edit  func(r *bboxclient.PinholeRule)
+		field string
+	}{
+		{"missing destination", func(r *bboxclient.PinholeRule) { r.DstIP = "" }, "dstip"},
+		{"IPv4 destination", func(r *bboxclient.PinholeRule) { r.DstIP = "192.168.1.10" }, "dstip"},
+		{"IPv4 source", func(r *bboxclient.PinholeRule) { r.SrcIP = "10.0.0.0/8" }, "srcip"},
+		{"bad prefix", func(r *bboxclient.PinholeRule) { r.SrcIP = "2001:db8::/129" }, "srcip"},
+		{"bad port", func(r *bboxclient.PinholeRule) { r.DstPorts = "70000" }, "dstports"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid
+			tt.edit(&rule)
+			var errs bboxclient.ValidationErrors
+			if !errors.As(rule.Validate(), &errs) || len(errs) != 1 || errs[0].Field != tt.field {
+				t.Errorf("Validate() = %v, want one error on %s", errs, tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// managedMarker is the separator GenerateUniqueDescription inserts between
+// the base description and the UUID of rules created by bboxcli.
+const managedMarker = "-bbcli-"
+
+// PlanAction is the kind of change a plan step applies to the router
+type PlanAction string
+
+const (
+	PlanCreate PlanAction = "create"
+	PlanUpdate PlanAction = "update"
+	PlanDelete PlanAction = "delete"
+)
+
+// FieldChange describes a single field whose value differs between two rules
+type FieldChange struct {
+	Field string `json:"field" yaml:"field"`
+	Old   string `json:"old" yaml:"old"`
+	New   string `json:"new" yaml:"new"`
+}
+
+func (fc FieldChange) String() string {
+	return fmt.Sprintf("%s: %q -> %q", fc.Field, fc.Old, fc.New)
+}
+
+// FirewallPlanStep is one create, update or delete operation of a plan.
+// Rule holds the rule as it will be sent to the router (or the rule being
+// removed for deletes).
+type FirewallPlanStep struct {
+	Action  PlanAction
+	Rule    FirewallRule
+	Changes []FieldChange
+}
+
+// FirewallPlan is the ordered list of operations needed to converge the
+// router to a desired rule set
+type FirewallPlan struct {
+	Steps []FirewallPlanStep
+}
+
+// Empty reports whether the plan has nothing to do
+func (p FirewallPlan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Count returns the number of steps with the given action
+func (p FirewallPlan) Count(action PlanAction) int {
+	n := 0
+	for _, s := range p.Steps {
+		if s.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// BaseDescription strips the "-bbcli-<uuid>" suffix added by
+// GenerateUniqueDescription. The second return value reports whether the
+// description carried the marker, i.e. whether the rule is managed by bboxcli.
+func BaseDescription(description string) (string, bool) {
+	i := strings.LastIndex(description, managedMarker)
+	if i < 0 {
+		return description, false
+	}
+	return description[:i], true
+}
+
+// WithBaseDescription replaces the base of description, keeping the
+// "-bbcli-<uuid>" suffix of managed rules so they stay managed
+func WithBaseDescription(description, base string) string {
+	if i := strings.LastIndex(description, managedMarker); i >= 0 {
+		return base + description[i:]
+	}
+	return base
+}
+
+// IsManaged reports whether the rule was created by bboxcli
+func (r *FirewallRule) IsManaged() bool {
+	_, managed := BaseDescription(r.Description)
+	return managed
+}
+
+// WithDefaults returns a copy of the rule with the values the router assumes
+// for unset fields filled in, so that rules read from a file compare equal to
+// the ones returned by the API.
+func (r FirewallRule) WithDefaults() FirewallRule {
+	if r.IPProtocol == "" {
+		r.IPProtocol = IPProtocolIPv4
+	}
+	if r.Protocols == "" {
+		r.Protocols = ProtocolAny
+	}
+	return r
+}
+
+// DiffFirewallRules lists the fields whose values differ between old and
+// updated, named as in the API form. ID and Utilisation are ignored since they
+// identify the rule or are maintained by the router.
+func DiffFirewallRules(old, updated FirewallRule) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, o, n interface{}) {
+		before, after := fmt.Sprint(o), fmt.Sprint(n)
+		if before != after {
+			changes = append(changes, FieldChange{Field: field, Old: before, New: after})
+		}
+	}
+
+	add("description", old.Description, updated.Description)
+	add("enable", old.Enable, updated.Enable)
+	add("action", old.Action, updated.Action)
+	add("srcipnot", old.SrcIPNot, updated.SrcIPNot)
+	add("srcip", old.SrcIP, updated.SrcIP)
+	add("srcportnot", old.SrcPortNot, updated.SrcPortNot)
+	add("srcports", old.SrcPorts, updated.SrcPorts)
+	add("dstipnot", old.DstIPNot, updated.DstIPNot)
+	add("dstip", old.DstIP, updated.DstIP)
+	add("dstportnot", old.DstPortNot, updated.DstPortNot)
+	add("dstports", old.DstPorts, updated.DstPorts)
+	add("order", old.Order, updated.Order)
+	add("protocols", old.Protocols, updated.Protocols)
+	add("ipprotocol", old.IPProtocol, updated.IPProtocol)
+	return changes
+}
+
+// PlanFirewallRules computes the operations needed to turn current into
+// desired. Desired rules are matched to existing managed rules by their base
+// description. Rules without the bboxcli marker are left alone unless prune is
+// set, in which case they are deleted too.
+func PlanFirewallRules(current, desired []FirewallRule, prune bool) (FirewallPlan, error) {
+	var plan FirewallPlan
+
+	wanted := make(map[string]FirewallRule, len(desired))
+	for _, rule := range desired {
+		base, _ := BaseDescription(rule.Description)
+		if base == "" {
+			return plan, fmt.Errorf("rule without description in desired set")
+		}
+		if _, dup := wanted[base]; dup {
+			return plan, fmt.Errorf("duplicate description %q in desired set", base)
+		}
+		wanted[base] = rule
+	}
+
+	existing := make(map[string]FirewallRule)
+	for _, rule := range current {
+		base, managed := BaseDescription(rule.Description)
+		if !managed {
+			if prune {
+				plan.Steps = append(plan.Steps, FirewallPlanStep{Action: PlanDelete, Rule: rule})
+			}
+			continue
+		}
+		_, seen := existing[base]
+		if _, ok := wanted[base]; seen || !ok {
+			// Duplicates of a managed rule and rules that are no longer
+			// wanted are removed
+			plan.Steps = append(plan.Steps, FirewallPlanStep{Action: PlanDelete, Rule: rule})
+			continue
+		}
+		existing[base] = rule
+	}
+
+	for _, rule := range desired {
+		base, _ := BaseDescription(rule.Description)
+		want := rule.WithDefaults()
+
+		have, ok := existing[base]
+		if !ok {
+			want.ID = 0
+			want.Description = GenerateUniqueDescription(base)
+			if want.Order == 0 {
+				want.Order = 1
+			}
+			plan.Steps = append(plan.Steps, FirewallPlanStep{Action: PlanCreate, Rule: want})
+			continue
+		}
+
+		want.ID = have.ID
+		want.Description = have.Description
+		want.Utilisation = have.Utilisation
+		if want.Order == 0 {
+			want.Order = have.Order
+		}
+		if changes := DiffFirewallRules(have.WithDefaults(), want); len(changes) > 0 {
+			plan.Steps = append(plan.Steps, FirewallPlanStep{Action: PlanUpdate, Rule: want, Changes: changes})
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplyFirewallPlan executes the plan against the router. Deletions run first
+// so that pruned rules do not interfere with the ones being created. Updates
+// only send the changed fields.
+func (fi *FirewallInterface) ApplyFirewallPlan(plan FirewallPlan) error {
+	return fi.ApplyFirewallPlanContext(context.Background(), plan)
+}
+
+// ApplyFirewallPlanContext is like ApplyFirewallPlan but bound to ctx
+func (fi *FirewallInterface) ApplyFirewallPlanContext(ctx context.Context, plan FirewallPlan) error {
+	for _, action := range []PlanAction{PlanDelete, PlanUpdate, PlanCreate} {
+		for _, step := range plan.Steps {
+			if step.Action != action {
+				continue
+			}
+
+			var err error
+			switch step.Action {
+			case PlanDelete:
+				err = fi.DeleteFirewallRuleContext(ctx, fmt.Sprintf("%d", step.Rule.ID))
+			case PlanUpdate:
+				err = fi.PatchFirewallRuleContext(ctx, step.Rule.ID, step.Changes)
+			case PlanCreate:
+				err = fi.AddFirewallRuleContext(ctx, step.Rule)
+			}
+			if err != nil {
+				return fmt.Errorf("%s %q: %w", step.Action, step.Rule.Description, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package client_test
+
+import (
+	"strings"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func TestBaseDescription(t *testing.T) {
+	tests := []struct {
+		in      string
+		base    string
+		managed bool
+	}{
+		{"ssh", "ssh", false},
+		{bboxclient.GenerateUniqueDescription("ssh"), "ssh", true},
+		{bboxclient.GenerateUniqueDescription("a-bbcli-b"), "a-bbcli-b", true},
+	}
+	for _, tt := range tests {
+		base, managed := bboxclient.BaseDescription(tt.in)
+		if base != tt.base || managed != tt.managed {
+			t.Errorf("BaseDescription(%q) = %q, %t; want %q, %t", tt.in, base, managed, tt.base, tt.managed)
+		}
+	}
+}
+
+func TestWithBaseDescription(t *testing.T) {
+	if got := bboxclient.WithBaseDescription("ssh", "sshd"); got != "sshd" {
+		t.Errorf("unmanaged rename = %q, want %q", got, "sshd")
+	}
+
+	managed := bboxclient.WithBaseDescription(bboxclient.GenerateUniqueDescription("ssh"), "sshd")
+	if base, ok := bboxclient.BaseDescription(managed); base != "sshd" || !ok {
+		t.Errorf("managed rename = %q, want base sshd with the marker kept", managed)
+	}
+}
+
+func TestPlanFirewallRules(t *testing.T) {
+	current := []bboxclient.FirewallRule{
+		{ID: 1, Description: "ssh-bbcli-1", Action: bboxclient.ActionAllow, DstPorts: "22", Order: 1,
+			Protocols: bboxclient.ProtocolAny, IPProtocol: bboxclient.IPProtocolIPv4},
+		{ID: 2, Description: "web-bbcli-2", Action: bboxclient.ActionAllow, DstPorts: "80", Order: 2,
+			Protocols: bboxclient.ProtocolAny, IPProtocol: bboxclient.IPProtocolIPv4},
+		{ID: 3, Description: "old-bbcli-3", Action: bboxclient.ActionDeny},
+		{ID: 4, Description: "manual", Action: bboxclient.ActionDeny},
+	}
+	desired := []bboxclient.FirewallRule{
+		{Description: "ssh", Action: bboxclient.ActionAllow, DstPorts: "22"},
+		{Description: "web", Action: bboxclient.ActionAllow, DstPorts: "80,443"},
+		{Description: "dns", Action: bboxclient.ActionAllow, DstPorts: "53"},
+	}
+
+	plan, err := bboxclient.PlanFirewallRules(current, desired, false)
+	if err != nil {
+		t.Fatalf("PlanFirewallRules: %v", err)
+	}
+
+	got := map[bboxclient.PlanAction][]string{}
+	for _, step := range plan.Steps {
+		base, _ := bboxclient.BaseDescription(step.Rule.Description)
+		got[step.Action] = append(got[step.Action], base)
+	}
+	if strings.Join(got[bboxclient.PlanCreate], ",") != "dns" {
+		t.Errorf("creates = %v, want [dns]", got[bboxclient.PlanCreate])
+	}
+	if strings.Join(got[bboxclient.PlanUpdate], ",") != "web" {
+		t.Errorf("updates = %v, want [web]", got[bboxclient.PlanUpdate])
+	}
+	if strings.Join(got[bboxclient.PlanDelete], ",") != "old" {
+		t.Errorf("deletes = %v, want [old]", got[bboxclient.PlanDelete])
+	}
+
+	for _, step := range plan.Steps {
+		if step.Action == bboxclient.PlanUpdate {
+			if step.Rule.ID != 2 || step.Rule.Order != 2 {
+				t.Errorf("update kept ID %d order %d, want 2 and 2", step.Rule.ID, step.Rule.Order)
+			}
+			if len(step.Changes) != 1 || step.Changes[0].Field != "dstports" {
+				t.Errorf("changes = %v", step.Changes)
+			}
+		}
+	}
+}
+
+func TestPlanFirewallRulesPrune(t *testing.T) {
+	current := []bboxclient.FirewallRule{{ID: 4, Description: "manual"}}
+
+	plan, err := bboxclient.PlanFirewallRules(current, nil, true)
+	if err != nil {
+		t.Fatalf("PlanFirewallRules: %v", err)
+	}
+	if plan.Count(bboxclient.PlanDelete) != 1 {
+		t.Errorf("plan = %+v, want the unmanaged rule deleted", plan)
+	}
+}
+
+func TestPlanFirewallRulesDuplicate(t *testing.T) {
+	desired := []bboxclient.FirewallRule{{Description: "ssh"}, {Description: "ssh"}}
+	if _, err := bboxclient.PlanFirewallRules(nil, desired, false); err == nil {
+		t.Error("duplicate descriptions accepted")
+	}
+}
+
+func TestApplyFirewallPlan(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{Description: "gone-bbcli-x", Action: bboxclient.ActionDeny},
+		{Description: "manual", Action: bboxclient.ActionDeny},
+	})
+	desired := []bboxclient.FirewallRule{
+		{Description: "ssh", Enable: bboxclient.Enabled, Action: bboxclient.ActionAllow, DstPorts: "22"},
+	}
+
+	fw := client.Firewall()
+	current, _ := fw.GetFirewallRules()
+	plan, err := bboxclient.PlanFirewallRules(current, desired, false)
+	if err != nil {
+		t.Fatalf("PlanFirewallRules: %v", err)
+	}
+	if err := fw.ApplyFirewallPlan(plan); err != nil {
+		t.Fatalf("ApplyFirewallPlan: %v", err)
+	}
+
+	// A second plan against the converged router is empty
+	current, _ = fw.GetFirewallRules()
+	plan, _ = bboxclient.PlanFirewallRules(current, desired, false)
+	if !plan.Empty() {
+		t.Errorf("plan after apply is not empty: %+v", plan)
+	}
+	if len(current) != 2 || current[0].Description != "manual" {
+		t.Errorf("rules after apply = %+v", current)
+	}
+}
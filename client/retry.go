@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy tells how requests failing with a transport error, 429 or 5xx
+// are retried. Only GET, HEAD, PUT and DELETE requests are retried, and POST
+// requests whose context went through MarkRetrySafe.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt: 1 disables retries
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first retry, doubled for each of
+	// the next ones up to MaxDelay. Half of each wait is random.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy is used by clients created without WithRetry or
+// WithMaxAttempts. It does not retry.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 1,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// WithRetry sets the retry policy of the client
+func WithRetry(policy RetryPolicy) Option {
+	return func(bc *BboxClient) {
+		bc.retry = policy
+	}
+}
+
+// WithMaxAttempts sets how many times a request is tried, keeping the delays
+// of the current policy
+func WithMaxAttempts(attempts int) Option {
+	return func(bc *BboxClient) {
+		bc.retry.MaxAttempts = attempts
+	}
+}
+
+type retrySafeKey struct{}
+
+// MarkRetrySafe returns a context under which POST requests are retried too,
+// for calls that the router can safely receive twice
+func MarkRetrySafe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retrySafeKey{}, true)
+}
+
+// retryable reports whether req may be sent again
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		safe, _ := req.Context().Value(retrySafeKey{}).(bool)
+		return safe
+	}
+	return false
+}
+
+// retryStatus reports whether the router answered that it is busy
+func retryStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns the wait before the given retry, 1 for the first one.
+// A Retry-After header takes precedence over the backoff; the second return
+// value is false when it asks for more than MaxDelay.
+func (p RetryPolicy) retryDelay(retry int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if header := resp.Header.Get("Retry-After"); header != "" {
+			var wait time.Duration
+			if seconds, err := strconv.Atoi(header); err == nil {
+				wait = time.Duration(seconds) * time.Second
+			} else if at, err := http.ParseTime(header); err == nil {
+				wait = time.Until(at)
+			}
+			if wait < 0 {
+				wait = 0
+			}
+			return wait, wait <= p.MaxDelay
+		}
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0, true
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1)), true
+}
+
+// sendWithRetry sends req through attempt, trying again as allowed by the
+// retry policy. The last response or error is returned when all attempts
+// fail. A DELETE answered 404 after a dropped connection is reported as a
+// success, since the first attempt may have reached the router.
+func (bc *BboxClient) sendWithRetry(req *http.Request, attempt func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	policy := bc.retry
+	if policy.MaxAttempts <= 1 || !retryable(req) {
+		return attempt(req)
+	}
+
+	current := req
+	dropped := false
+	for n := 1; ; n++ {
+		resp, err := attempt(current)
+		if dropped && err == nil && req.Method == http.MethodDelete && resp.StatusCode == http.StatusNotFound {
+			// The attempt whose connection dropped deleted the resource
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+			return &http.Response{
+				Status:     "200 OK",
+				StatusCode: http.StatusOK,
+				Proto:      resp.Proto,
+				ProtoMajor: resp.ProtoMajor,
+				ProtoMinor: resp.ProtoMinor,
+				Header:     http.Header{},
+				Body:       http.NoBody,
+				Request:    resp.Request,
+			}, nil
+		}
+		dropped = dropped || err != nil
+		if n >= policy.MaxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil && !retryStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		wait, ok := policy.retryDelay(n, resp)
+		if !ok {
+			return resp, err
+		}
+		next, replayErr := bc.replay(req)
+		if replayErr != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		current = next
+	}
+}
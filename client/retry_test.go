@@ -0,0 +1,214 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	bboxclient "bbox-cli/client"
+	"bbox-cli/client/bboxtest"
+)
+
+// fastRetry retries without waiting noticeably
+var fastRetry = bboxclient.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+// failing answers the first n requests for rules matching method with status,
+// or with a transport error when status is 0. Login requests go through.
+func failing(method string, n, status int, header http.Header) bboxclient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return bboxclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != method || n == 0 || !strings.Contains(req.URL.Path, "/rules") {
+				return next.RoundTrip(req)
+			}
+			n--
+			if status == 0 {
+				return nil, errors.New("connection reset by peer")
+			}
+			if header == nil {
+				header = http.Header{}
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		})
+	}
+}
+
+// newRetryClient returns a logged in client whose requests go through mw
+func newRetryClient(t *testing.T, policy bboxclient.RetryPolicy, mw bboxclient.Middleware) (*bboxtest.Server, *bboxclient.BboxClient, *int) {
+	t.Helper()
+
+	server := bboxtest.NewServer(testPassword)
+	t.Cleanup(server.Close)
+
+	attempts := 0
+	client, err := bboxclient.NewClient(server.BaseURL(),
+		bboxclient.WithRetry(policy),
+		bboxclient.WithMiddleware(mw),
+		bboxclient.WithBeforeRequest(func(*http.Request) error {
+			attempts++
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatalf("BasicAuth: %v", err)
+	}
+	attempts = 0
+	return server, client, &attempts
+}
+
+func TestRetryBusyRouter(t *testing.T) {
+	server, client, attempts := newRetryClient(t, fastRetry,
+		failing(http.MethodGet, 2, http.StatusServiceUnavailable, nil))
+	server.SetFirewallRules([]bboxclient.FirewallRule{{ID: 1}})
+
+	if _, err := client.Firewall().GetFirewallRules(); err != nil {
+		t.Fatalf("GetFirewallRules: %v", err)
+	}
+	if *attempts != 3 {
+		t.Errorf("%d attempts, want 3", *attempts)
+	}
+}
+
+func TestRetryDroppedConnection(t *testing.T) {
+	server, client, _ := newRetryClient(t, fastRetry, failing(http.MethodPut, 1, 0, nil))
+	server.SetFirewallRules([]bboxclient.FirewallRule{{ID: 1, Description: "ssh"}})
+
+	if err := client.Firewall().UpdateFirewallRule(bboxclient.FirewallRule{ID: 1, Description: "web"}); err != nil {
+		t.Fatalf("UpdateFirewallRule: %v", err)
+	}
+	if got := server.FirewallRules()[0].Description; got != "web" {
+		t.Errorf("replayed body lost: description = %q", got)
+	}
+}
+
+func TestRetryDeleteAppliedBeforeDrop(t *testing.T) {
+	// The router deletes the rule but the connection drops before the answer
+	applied := func(next http.RoundTripper) http.RoundTripper {
+		dropped := false
+		return bboxclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if req.Method != http.MethodDelete || dropped || err != nil {
+				return resp, err
+			}
+			dropped = true
+			resp.Body.Close()
+			return nil, errors.New("connection reset by peer")
+		})
+	}
+	server, client, attempts := newRetryClient(t, fastRetry, applied)
+	server.SetFirewallRules([]bboxclient.FirewallRule{{ID: 1, Description: "ssh"}, {ID: 2, Description: "web"}})
+
+	if err := client.Firewall().DeleteFirewallRule("1"); err != nil {
+		t.Fatalf("DeleteFirewallRule: %v", err)
+	}
+	if rules := server.FirewallRules(); len(rules) != 1 || rules[0].ID != 2 {
+		t.Errorf("rules = %+v, want only rule 2", rules)
+	}
+
+	// A rule that was never there is still reported missing
+	*attempts = 0
+	err := client.Firewall().DeleteFirewallRule("42")
+	if !errors.Is(err, bboxclient.ErrFirewallRuleNotFound) {
+		t.Errorf("err = %v, want ErrFirewallRuleNotFound", err)
+	}
+	if *attempts != 1 {
+		t.Errorf("%d attempts, want 1", *attempts)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	_, client, attempts := newRetryClient(t, fastRetry,
+		failing(http.MethodGet, 10, http.StatusBadGateway, nil))
+
+	_, err := client.Firewall().GetFirewallRules()
+	if !hasStatusCode(err, http.StatusBadGateway) {
+		t.Errorf("err = %v, want the last 502", err)
+	}
+	if *attempts != fastRetry.MaxAttempts {
+		t.Errorf("%d attempts, want %d", *attempts, fastRetry.MaxAttempts)
+	}
+}
+
+func TestRetryPostOnlyWhenSafe(t *testing.T) {
+	_, client, attempts := newRetryClient(t, fastRetry,
+		failing(http.MethodPost, 1, http.StatusServiceUnavailable, nil))
+
+	if err := client.Firewall().AddFirewallRule(bboxclient.FirewallRule{Description: "x"}); err == nil {
+		t.Error("POST was retried")
+	}
+	if *attempts != 1 {
+		t.Errorf("%d attempts, want 1", *attempts)
+	}
+
+	*attempts = 0
+	ctx := bboxclient.MarkRetrySafe(context.Background())
+	if err := client.Firewall().AddFirewallRuleContext(ctx, bboxclient.FirewallRule{Description: "x"}); err != nil {
+		t.Errorf("AddFirewallRule marked safe: %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	// The backoff would wait an hour: Retry-After must take precedence
+	slow := bboxclient.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	_, client, attempts := newRetryClient(t, slow,
+		failing(http.MethodGet, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}}))
+
+	start := time.Now()
+	if _, err := client.Firewall().GetFirewallRules(); err != nil {
+		t.Fatalf("GetFirewallRules: %v", err)
+	}
+	if *attempts != 2 || time.Since(start) > time.Second {
+		t.Errorf("%d attempts in %s", *attempts, time.Since(start))
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	_, client, attempts := newRetryClient(t, fastRetry,
+		failing(http.MethodGet, 1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"120"}}))
+
+	if _, err := client.Firewall().GetFirewallRules(); !hasStatusCode(err, http.StatusServiceUnavailable) {
+		t.Errorf("err = %v, want the 503", err)
+	}
+	if *attempts != 1 {
+		t.Errorf("%d attempts, want 1", *attempts)
+	}
+}
+
+func TestRetryStopsWithContext(t *testing.T) {
+	slow := bboxclient.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	_, client, _ := newRetryClient(t, slow,
+		failing(http.MethodGet, 5, http.StatusServiceUnavailable, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Firewall().GetFirewallRulesContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestNoRetryByDefault(t *testing.T) {
+	_, client, attempts := newRetryClient(t, bboxclient.DefaultRetryPolicy,
+		failing(http.MethodGet, 1, http.StatusServiceUnavailable, nil))
+
+	if _, err := client.Firewall().GetFirewallRules(); err == nil {
+		t.Error("request retried by default")
+	}
+	if *attempts != 1 {
+		t.Errorf("%d attempts, want 1", *attempts)
+	}
+}
+
+func hasStatusCode(err error, status int) bool {
+	var apiErr *bboxclient.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
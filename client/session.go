@@ -0,0 +1,65 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// tokenExpiryMargin is how long before its expiry a device token stops
+// being considered valid
+const tokenExpiryMargin = time.Minute
+
+// Session is the authentication state of a client: the session cookies set
+// by /login and the device token. It can be persisted between runs to avoid
+// logging in on every invocation.
+type Session struct {
+	URL     string         `json:"url"`
+	Cookies []*http.Cookie `json:"cookies"`
+	Bearer  *DeviceToken   `json:"bearer"`
+}
+
+// Session returns the current authentication state of the client
+func (bc *BboxClient) Session() Session {
+	return Session{
+		URL:     bc.Url.String(),
+		Cookies: bc.GetCookies(),
+		Bearer:  bc.Bearer(),
+	}
+}
+
+// Resume restores a session saved with BboxClient.Session. The password is
+// remembered so that the session is renewed if the router rejects it.
+func (ai *AuthInterface) Resume(session Session, password string) {
+	cookies := make([]*http.Cookie, 0, len(session.Cookies))
+	for _, c := range session.Cookies {
+		restored := *c
+		restored.Path = "/"
+		cookies = append(cookies, &restored)
+	}
+	ai.Client.Client.Jar.SetCookies(ai.Client.Url, cookies)
+	ai.Client.setBearer(session.Bearer)
+	ai.Client.password = password
+}
+
+// ExpiresAt parses the expiry date of the token
+func (t *DeviceToken) ExpiresAt() (time.Time, error) {
+	expires, err := time.Parse(time.RFC3339, t.Expires)
+	if err != nil {
+		// Some firmwares omit the colon in the zone offset
+		expires, err = time.Parse("2006-01-02T15:04:05-0700", t.Expires)
+	}
+	return expires, err
+}
+
+// Valid reports whether the token can still be used for at least
+// tokenExpiryMargin. Tokens with an unreadable expiry are never valid.
+func (t *DeviceToken) Valid() bool {
+	if t == nil || t.Token == "" {
+		return false
+	}
+	expires, err := t.ExpiresAt()
+	if err != nil {
+		return false
+	}
+	return time.Until(expires) > tokenExpiryMargin
+}
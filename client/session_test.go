@@ -0,0 +1,68 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	bboxclient "bbox-cli/client"
+)
+
+func countRequests(requests []string, want string) int {
+	n := 0
+	for _, r := range requests {
+		if r == want {
+			n++
+		}
+	}
+	return n
+}
+
+func TestResumeSession(t *testing.T) {
+	server, first := newTestClient(t)
+	session := first.Session()
+
+	client, _ := server.NewClient()
+	client.Auth().Resume(session, testPassword)
+
+	if err := client.Firewall().AddFirewallRule(bboxclient.FirewallRule{Description: "x"}); err != nil {
+		t.Fatalf("AddFirewallRule with resumed session: %v", err)
+	}
+	if n := countRequests(server.Requests(), "POST /login"); n != 1 {
+		t.Errorf("logged in %d times, want 1", n)
+	}
+}
+
+func TestReauthenticateOnUnauthorized(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{{ID: 1}})
+	server.ExpireSessions()
+
+	if _, err := client.Firewall().GetFirewallRules(); err != nil {
+		t.Fatalf("GetFirewallRules after expiry: %v", err)
+	}
+	if err := client.Firewall().DeleteFirewallRule("1"); err != nil {
+		t.Fatalf("DeleteFirewallRule after expiry: %v", err)
+	}
+	if n := countRequests(server.Requests(), "POST /login"); n != 2 {
+		t.Errorf("logged in %d times, want 2", n)
+	}
+}
+
+func TestDeviceTokenValid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token *bboxclient.DeviceToken
+		want  bool
+	}{
+		{"nil", nil, false},
+		{"future", &bboxclient.DeviceToken{Token: "t", Expires: time.Now().Add(time.Hour).Format(time.RFC3339)}, true},
+		{"no colon offset", &bboxclient.DeviceToken{Token: "t", Expires: time.Now().Add(time.Hour).Format("2006-01-02T15:04:05-0700")}, true},
+		{"almost expired", &bboxclient.DeviceToken{Token: "t", Expires: time.Now().Add(30 * time.Second).Format(time.RFC3339)}, false},
+		{"garbage", &bboxclient.DeviceToken{Token: "t", Expires: "soon"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.token.Valid(); got != tt.want {
+			t.Errorf("%s: Valid() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
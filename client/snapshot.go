@@ -0,0 +1,361 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SnapshotVersion is the format version written by TakeSnapshot
+const SnapshotVersion = 1
+
+// Sections a snapshot can contain
+const (
+	SnapshotFirewall = "firewall"
+	SnapshotNat      = "nat"
+)
+
+// RestoreUnchanged marks the restore steps of rules already on the router as
+// saved. They are kept in the plan to map their IDs.
+const RestoreUnchanged PlanAction = "unchanged"
+
+// SnapshotMeta records where and when a snapshot was taken
+type SnapshotMeta struct {
+	Version   int       `json:"version" yaml:"version"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	Model     string    `json:"model" yaml:"model"`
+	Firmware  string    `json:"firmware" yaml:"firmware"`
+	Serial    string    `json:"serial" yaml:"serial"`
+	Sections  []string  `json:"sections" yaml:"sections"`
+}
+
+// Snapshot is a backup of the rule sets of a router
+type Snapshot struct {
+	Meta     SnapshotMeta   `json:"meta" yaml:"meta"`
+	Firewall []FirewallRule `json:"firewall,omitempty" yaml:"firewall,omitempty"`
+	Nat      []NatRule      `json:"nat,omitempty" yaml:"nat,omitempty"`
+}
+
+// Has reports whether the snapshot contains the given section
+func (s Snapshot) Has(section string) bool {
+	for _, name := range s.Meta.Sections {
+		if name == section {
+			return true
+		}
+	}
+	return false
+}
+
+// TakeSnapshot saves the given sections, all of them when none is given,
+// along with the model and firmware of the router
+func (bc *BboxClient) TakeSnapshot(sections ...string) (Snapshot, error) {
+	return bc.TakeSnapshotContext(context.Background(), sections...)
+}
+
+// TakeSnapshotContext is like TakeSnapshot but bound to ctx
+func (bc *BboxClient) TakeSnapshotContext(ctx context.Context, sections ...string) (Snapshot, error) {
+	if len(sections) == 0 {
+		sections = []string{SnapshotFirewall, SnapshotNat}
+	}
+
+	device, err := bc.GetDeviceInfoContext(ctx)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read device information: %w", err)
+	}
+
+	snapshot := Snapshot{Meta: SnapshotMeta{
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Model:     device.ModelName,
+		Firmware:  device.Main.Version,
+		Serial:    device.SerialNumber,
+	}}
+	for _, section := range sections {
+		if snapshot.Has(section) {
+			continue
+		}
+		switch section {
+		case SnapshotFirewall:
+			snapshot.Firewall, err = bc.Firewall().GetFirewallRulesContext(ctx)
+		case SnapshotNat:
+			snapshot.Nat, err = bc.Nat().GetNatRulesContext(ctx)
+		default:
+			return Snapshot{}, fmt.Errorf("unknown snapshot section %q", section)
+		}
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.Meta.Sections = append(snapshot.Meta.Sections, section)
+	}
+	return snapshot, nil
+}
+
+// RestoreStep is one rule of a snapshot and what restoring it takes.
+// SourceID is its ID in the snapshot and TargetID the ID of the matching rule
+// on the router, 0 for rules to create.
+type RestoreStep struct {
+	Section     string
+	Action      PlanAction
+	SourceID    int
+	TargetID    int
+	Description string
+	Changes     []FieldChange
+
+	// Exactly one of these is set, depending on Section
+	FirewallRule *FirewallRule
+	NatRule      *NatRule
+}
+
+// RestorePlan lists the steps restoring a snapshot. Rules on the router that
+// are not in the snapshot are left alone.
+type RestorePlan struct {
+	Steps []RestoreStep
+}
+
+// Empty reports whether restoring would change nothing
+func (p RestorePlan) Empty() bool {
+	return p.Count(PlanCreate)+p.Count(PlanUpdate) == 0
+}
+
+// Count returns the number of steps with the given action
+func (p RestorePlan) Count(action PlanAction) int {
+	n := 0
+	for _, s := range p.Steps {
+		if s.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// IDMapping maps, per section, the ID of each snapshot rule to the ID of the
+// rule restored from it
+type IDMapping map[string]map[int]int
+
+// PlanSnapshotRestore matches the snapshot rules to the current ones and
+// returns the creates and updates needed. Firewall rules are matched by
+// description; NAT rules by description, or by their ports and target when
+// they have none.
+func PlanSnapshotRestore(snapshot Snapshot, firewall []FirewallRule, nat []NatRule) RestorePlan {
+	var plan RestorePlan
+
+	if snapshot.Has(SnapshotFirewall) {
+		current := make(map[string][]FirewallRule)
+		for _, rule := range sortedByID(firewall) {
+			current[rule.Description] = append(current[rule.Description], rule)
+		}
+		for _, saved := range snapshot.Firewall {
+			saved := saved.WithDefaults()
+			step := RestoreStep{
+				Section:      SnapshotFirewall,
+				Action:       PlanCreate,
+				SourceID:     saved.ID,
+				Description:  saved.Description,
+				FirewallRule: &saved,
+			}
+			if matches := current[saved.Description]; len(matches) > 0 {
+				have := matches[0]
+				current[saved.Description] = matches[1:]
+				step.TargetID = have.ID
+				step.Changes = DiffFirewallRules(have.WithDefaults(), saved)
+				step.Action = PlanUpdate
+				if len(step.Changes) == 0 {
+					step.Action = RestoreUnchanged
+				}
+			}
+			plan.Steps = append(plan.Steps, step)
+		}
+	}
+
+	if snapshot.Has(SnapshotNat) {
+		current := make(map[string][]NatRule)
+		for _, rule := range nat {
+			current[natKey(rule)] = append(current[natKey(rule)], rule)
+		}
+		for _, saved := range snapshot.Nat {
+			saved := saved
+			step := RestoreStep{
+				Section:     SnapshotNat,
+				Action:      PlanCreate,
+				SourceID:    saved.ID,
+				Description: saved.Description,
+				NatRule:     &saved,
+			}
+			key := natKey(saved)
+			if matches := current[key]; len(matches) > 0 {
+				have := matches[0]
+				current[key] = matches[1:]
+				step.TargetID = have.ID
+				step.Changes = diffNatRules(have, saved)
+				step.Action = PlanUpdate
+				if len(step.Changes) == 0 {
+					step.Action = RestoreUnchanged
+				}
+			}
+			plan.Steps = append(plan.Steps, step)
+		}
+	}
+
+	return plan
+}
+
+// PlanRestore reads the current rules of the sections in the snapshot and
+// plans its restoration
+func (bc *BboxClient) PlanRestore(snapshot Snapshot) (RestorePlan, error) {
+	return bc.PlanRestoreContext(context.Background(), snapshot)
+}
+
+// PlanRestoreContext is like PlanRestore but bound to ctx
+func (bc *BboxClient) PlanRestoreContext(ctx context.Context, snapshot Snapshot) (RestorePlan, error) {
+	var firewall []FirewallRule
+	var nat []NatRule
+	var err error
+
+	if snapshot.Has(SnapshotFirewall) {
+		if firewall, err = bc.Firewall().GetFirewallRulesContext(ctx); err != nil {
+			return RestorePlan{}, err
+		}
+	}
+	if snapshot.Has(SnapshotNat) {
+		if nat, err = bc.Nat().GetNatRulesContext(ctx); err != nil {
+			return RestorePlan{}, err
+		}
+	}
+	return PlanSnapshotRestore(snapshot, firewall, nat), nil
+}
+
+// ApplyRestorePlan executes the plan and returns the ID each snapshot rule
+// has on the router afterwards. On error the mapping covers the steps done
+// so far.
+func (bc *BboxClient) ApplyRestorePlan(plan RestorePlan) (IDMapping, error) {
+	return bc.ApplyRestorePlanContext(context.Background(), plan)
+}
+
+// ApplyRestorePlanContext is like ApplyRestorePlan but bound to ctx
+func (bc *BboxClient) ApplyRestorePlanContext(ctx context.Context, plan RestorePlan) (IDMapping, error) {
+	mapping := IDMapping{SnapshotFirewall: {}, SnapshotNat: {}}
+	created := make(map[string][]RestoreStep)
+
+	for _, step := range plan.Steps {
+		var err error
+		switch {
+		case step.Action == RestoreUnchanged:
+		case step.Section == SnapshotFirewall:
+			rule := *step.FirewallRule
+			rule.ID = step.TargetID
+			if step.Action == PlanCreate {
+				err = bc.Firewall().AddFirewallRuleContext(ctx, rule)
+			} else {
+				err = bc.Firewall().PatchFirewallRuleContext(ctx, rule.ID, step.Changes)
+			}
+		case step.Section == SnapshotNat:
+			rule := *step.NatRule
+			rule.ID = step.TargetID
+			if step.Action == PlanCreate {
+				err = bc.Nat().AddNatRuleContext(ctx, rule)
+			} else {
+				err = bc.Nat().UpdateNatRuleContext(ctx, rule)
+			}
+		}
+		if err != nil {
+			return mapping, fmt.Errorf("%s %s rule %q: %w", step.Action, step.Section, step.Description, err)
+		}
+
+		if step.Action == PlanCreate {
+			created[step.Section] = append(created[step.Section], step)
+		} else {
+			mapping[step.Section][step.SourceID] = step.TargetID
+		}
+	}
+
+	return mapping, bc.mapCreatedRules(ctx, plan, created, mapping)
+}
+
+// mapCreatedRules finds the IDs the router assigned to the created rules:
+// those with a matching key that no step of the plan targeted
+func (bc *BboxClient) mapCreatedRules(ctx context.Context, plan RestorePlan, created map[string][]RestoreStep, mapping IDMapping) error {
+	known := make(map[string]map[int]bool)
+	for _, step := range plan.Steps {
+		if known[step.Section] == nil {
+			known[step.Section] = make(map[int]bool)
+		}
+		known[step.Section][step.TargetID] = true
+	}
+
+	if steps := created[SnapshotFirewall]; len(steps) > 0 {
+		rules, err := bc.Firewall().GetFirewallRulesContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to map new firewall rule IDs: %w", err)
+		}
+		fresh := make(map[string][]int)
+		for _, rule := range sortedByID(rules) {
+			if !known[SnapshotFirewall][rule.ID] {
+				fresh[rule.Description] = append(fresh[rule.Description], rule.ID)
+			}
+		}
+		for _, step := range steps {
+			if ids := fresh[step.Description]; len(ids) > 0 {
+				mapping[SnapshotFirewall][step.SourceID] = ids[0]
+				fresh[step.Description] = ids[1:]
+			}
+		}
+	}
+
+	if steps := created[SnapshotNat]; len(steps) > 0 {
+		rules, err := bc.Nat().GetNatRulesContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to map new NAT rule IDs: %w", err)
+		}
+		fresh := make(map[string][]int)
+		for _, rule := range rules {
+			if !known[SnapshotNat][rule.ID] {
+				fresh[natKey(rule)] = append(fresh[natKey(rule)], rule.ID)
+			}
+		}
+		for _, step := range steps {
+			key := natKey(*step.NatRule)
+			if ids := fresh[key]; len(ids) > 0 {
+				mapping[SnapshotNat][step.SourceID] = ids[0]
+				fresh[key] = ids[1:]
+			}
+		}
+	}
+	return nil
+}
+
+// natKey identifies a NAT rule across routers
+func natKey(rule NatRule) string {
+	if rule.Description != "" {
+		return rule.Description
+	}
+	return fmt.Sprintf("%s %s:%s -> %s:%s",
+		rule.Protocol, rule.SrcIP, rule.SrcPorts, rule.TargetIP, rule.TargetPorts)
+}
+
+// diffNatRules lists the fields whose values differ between two NAT rules,
+// named as in the API form
+func diffNatRules(old, updated NatRule) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, o, n interface{}) {
+		before, after := fmt.Sprint(o), fmt.Sprint(n)
+		if before != after {
+			changes = append(changes, FieldChange{Field: field, Old: before, New: after})
+		}
+	}
+
+	add("description", old.Description, updated.Description)
+	add("enable", old.Enable, updated.Enable)
+	add("protocol", old.Protocol, updated.Protocol)
+	add("externalip", old.SrcIP, updated.SrcIP)
+	add("externalport", old.SrcPorts, updated.SrcPorts)
+	add("internalip", old.TargetIP, updated.TargetIP)
+	add("internalport", old.TargetPorts, updated.TargetPorts)
+	return changes
+}
+
+func sortedByID(rules []FirewallRule) []FirewallRule {
+	sorted := append([]FirewallRule(nil), rules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
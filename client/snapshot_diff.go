@@ -0,0 +1,118 @@
+package client
+
+import "fmt"
+
+// DiffKind tells how a rule differs between two snapshots
+type DiffKind string
+
+const (
+	DiffAdded    DiffKind = "added"
+	DiffRemoved  DiffKind = "removed"
+	DiffModified DiffKind = "modified"
+)
+
+// RuleDiff is a rule that was added, removed or modified between two
+// snapshots. OldID and NewID are its IDs on each side, 0 when absent.
+type RuleDiff struct {
+	Section string        `json:"section" yaml:"section"`
+	Kind    DiffKind      `json:"kind" yaml:"kind"`
+	Name    string        `json:"name" yaml:"name"`
+	OldID   int           `json:"old_id,omitempty" yaml:"old_id,omitempty"`
+	NewID   int           `json:"new_id,omitempty" yaml:"new_id,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty" yaml:"changes,omitempty"`
+}
+
+// DiffSnapshots compares the sections present in both snapshots. Rule IDs
+// change when rules are re-created, so firewall rules are matched by their
+// description without the bboxcli marker and NAT rules as for restores.
+func DiffSnapshots(old, updated Snapshot) []RuleDiff {
+	var diffs []RuleDiff
+
+	if old.Has(SnapshotFirewall) && updated.Has(SnapshotFirewall) {
+		key := func(r FirewallRule) string {
+			base, _ := BaseDescription(r.Description)
+			return base
+		}
+		pairs := pairRules(sortedByID(old.Firewall), sortedByID(updated.Firewall), key)
+		for _, p := range pairs {
+			d := RuleDiff{Section: SnapshotFirewall, Name: p.key}
+			switch {
+			case p.old == nil:
+				d.Kind, d.NewID = DiffAdded, p.new.ID
+			case p.new == nil:
+				d.Kind, d.OldID = DiffRemoved, p.old.ID
+			default:
+				d.Kind, d.OldID, d.NewID = DiffModified, p.old.ID, p.new.ID
+				for _, c := range DiffFirewallRules(p.old.WithDefaults(), p.new.WithDefaults()) {
+					// Only the marker differs: the rule was re-created
+					if c.Field != "description" {
+						d.Changes = append(d.Changes, c)
+					}
+				}
+				if len(d.Changes) == 0 {
+					continue
+				}
+			}
+			diffs = append(diffs, d)
+		}
+	}
+
+	if old.Has(SnapshotNat) && updated.Has(SnapshotNat) {
+		for _, p := range pairRules(old.Nat, updated.Nat, natKey) {
+			d := RuleDiff{Section: SnapshotNat, Name: p.key}
+			switch {
+			case p.old == nil:
+				d.Kind, d.NewID = DiffAdded, p.new.ID
+			case p.new == nil:
+				d.Kind, d.OldID = DiffRemoved, p.old.ID
+			default:
+				d.Kind, d.OldID, d.NewID = DiffModified, p.old.ID, p.new.ID
+				if d.Changes = diffNatRules(*p.old, *p.new); len(d.Changes) == 0 {
+					continue
+				}
+			}
+			diffs = append(diffs, d)
+		}
+	}
+
+	return diffs
+}
+
+// rulePair holds the rules sharing a key on each side; one of them is nil
+// when the rule exists on one side only
+type rulePair[T any] struct {
+	key      string
+	old, new *T
+}
+
+// pairRules matches rules by key, pairing duplicates in the order given.
+// Pairs follow the order of old, then the rules only in updated.
+func pairRules[T any](old, updated []T, key func(T) string) []rulePair[T] {
+	remaining := make(map[string][]int)
+	for i := range updated {
+		k := key(updated[i])
+		remaining[k] = append(remaining[k], i)
+	}
+
+	var pairs []rulePair[T]
+	used := make(map[int]bool)
+	for i := range old {
+		p := rulePair[T]{key: key(old[i]), old: &old[i]}
+		if idx := remaining[p.key]; len(idx) > 0 {
+			p.new = &updated[idx[0]]
+			used[idx[0]] = true
+			remaining[p.key] = idx[1:]
+		}
+		pairs = append(pairs, p)
+	}
+	for i := range updated {
+		if !used[i] {
+			pairs = append(pairs, rulePair[T]{key: key(updated[i]), new: &updated[i]})
+		}
+	}
+	return pairs
+}
+
+func (d RuleDiff) String() string {
+	return fmt.Sprintf("%s %s rule %q", d.Kind, d.Section, d.Name)
+}
@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	sections := []string{bboxclient.SnapshotFirewall, bboxclient.SnapshotNat}
+	old := bboxclient.Snapshot{
+		Meta: bboxclient.SnapshotMeta{Sections: sections},
+		Firewall: []bboxclient.FirewallRule{
+			{ID: 1, Description: "ssh-bbcli-aaaa", Action: bboxclient.ActionAllow, DstPorts: "22"},
+			{ID: 2, Description: "web", Action: bboxclient.ActionAllow, DstPorts: "443"},
+			{ID: 3, Description: "old", Action: bboxclient.ActionDeny},
+		},
+		Nat: []bboxclient.NatRule{
+			{ID: 1, Description: "game", SrcPorts: "27015", TargetIP: "192.168.1.30"},
+		},
+	}
+	updated := bboxclient.Snapshot{
+		Meta: bboxclient.SnapshotMeta{Sections: sections},
+		Firewall: []bboxclient.FirewallRule{
+			// Re-created by apply: new ID and marker, same content
+			{ID: 8, Description: "ssh-bbcli-bbbb", Action: bboxclient.ActionAllow, DstPorts: "22"},
+			{ID: 2, Description: "web", Action: bboxclient.ActionAllow, DstPorts: "8443"},
+			{ID: 9, Description: "new", Action: bboxclient.ActionDeny},
+		},
+		Nat: []bboxclient.NatRule{
+			{ID: 4, Description: "game", SrcPorts: "27015", TargetIP: "192.168.1.31"},
+		},
+	}
+
+	diffs := bboxclient.DiffSnapshots(old, updated)
+	want := []struct {
+		section string
+		kind    bboxclient.DiffKind
+		name    string
+	}{
+		{bboxclient.SnapshotFirewall, bboxclient.DiffModified, "web"},
+		{bboxclient.SnapshotFirewall, bboxclient.DiffRemoved, "old"},
+		{bboxclient.SnapshotFirewall, bboxclient.DiffAdded, "new"},
+		{bboxclient.SnapshotNat, bboxclient.DiffModified, "game"},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("diffs = %v, want %d entries", diffs, len(want))
+	}
+	for i, w := range want {
+		d := diffs[i]
+		if d.Section != w.section || d.Kind != w.kind || d.Name != w.name {
+			t.Errorf("diff %d = %v, want %s %s %q", i, d, w.kind, w.section, w.name)
+		}
+	}
+
+	if c := diffs[0].Changes; len(c) != 1 || c[0].Field != "dstports" || c[0].Old != "443" || c[0].New != "8443" {
+		t.Errorf("web changes = %v", c)
+	}
+	if d := diffs[3]; d.OldID != 1 || d.NewID != 4 || len(d.Changes) != 1 || d.Changes[0].Field != "internalip" {
+		t.Errorf("NAT diff = %+v", d)
+	}
+}
+
+func TestDiffSnapshotsCommonSectionsOnly(t *testing.T) {
+	old := bboxclient.Snapshot{
+		Meta:     bboxclient.SnapshotMeta{Sections: []string{bboxclient.SnapshotFirewall}},
+		Firewall: []bboxclient.FirewallRule{{ID: 1, Description: "ssh"}},
+	}
+	updated := bboxclient.Snapshot{
+		Meta: bboxclient.SnapshotMeta{Sections: []string{bboxclient.SnapshotNat}},
+		Nat:  []bboxclient.NatRule{{ID: 1, Description: "web"}},
+	}
+	if diffs := bboxclient.DiffSnapshots(old, updated); len(diffs) != 0 {
+		t.Errorf("diffs = %v, want none", diffs)
+	}
+}
@@ -0,0 +1,147 @@
+package client_test
+
+import (
+	"testing"
+
+	bboxclient "bbox-cli/client"
+	"bbox-cli/client/bboxtest"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{{ID: 1, Description: "ssh", DstPorts: "22"}})
+	server.SetNatRules([]bboxclient.NatRule{{ID: 1, Description: "web", TargetIP: "192.168.1.10"}})
+
+	snapshot, err := client.TakeSnapshot(bboxclient.SnapshotFirewall)
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	if snapshot.Meta.Model != server.Device.ModelName || snapshot.Meta.Firmware != server.Device.Main.Version {
+		t.Errorf("meta = %+v", snapshot.Meta)
+	}
+	if snapshot.Meta.CreatedAt.IsZero() || snapshot.Meta.Version != bboxclient.SnapshotVersion {
+		t.Errorf("meta = %+v", snapshot.Meta)
+	}
+	if !snapshot.Has(bboxclient.SnapshotFirewall) || snapshot.Has(bboxclient.SnapshotNat) {
+		t.Errorf("sections = %v, want firewall only", snapshot.Meta.Sections)
+	}
+	if len(snapshot.Firewall) != 1 || snapshot.Nat != nil {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+
+	if _, err := client.TakeSnapshot("dhcp"); err == nil {
+		t.Error("TakeSnapshot accepted an unknown section")
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	source, sourceClient := newTestClient(t)
+	source.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "ssh", Action: bboxclient.ActionAllow, DstPorts: "22", Order: 1},
+		{ID: 2, Description: "block", Action: bboxclient.ActionDeny, SrcIP: "10.0.0.0/8", Order: 2},
+		{ID: 3, Description: "web", Action: bboxclient.ActionAllow, DstPorts: "443", Order: 3},
+	})
+	source.SetNatRules([]bboxclient.NatRule{
+		{ID: 7, Protocol: bboxclient.ProtocolTCP, SrcPorts: "8080", TargetIP: "192.168.1.10", TargetPorts: "80"},
+	})
+
+	snapshot, err := sourceClient.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+
+	// The replacement box already has some of the rules, one of them changed,
+	// and rules of its own
+	target := bboxtest.NewServer(testPassword)
+	t.Cleanup(target.Close)
+	target.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 10, Description: "local", Action: bboxclient.ActionAllow, Order: 9},
+		{ID: 11, Description: "ssh", Action: bboxclient.ActionAllow, DstPorts: "22", Order: 1},
+		{ID: 12, Description: "block", Action: bboxclient.ActionDeny, SrcIP: "10.0.0.1", Order: 2},
+	})
+	client, err := target.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Auth().BasicAuth(testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := client.PlanRestore(snapshot)
+	if err != nil {
+		t.Fatalf("PlanRestore: %v", err)
+	}
+	if c, u, n := plan.Count(bboxclient.PlanCreate), plan.Count(bboxclient.PlanUpdate), plan.Count(bboxclient.RestoreUnchanged); c != 2 || u != 1 || n != 1 {
+		t.Errorf("plan has %d creates, %d updates, %d unchanged; want 2, 1, 1", c, u, n)
+	}
+
+	mapping, err := client.ApplyRestorePlan(plan)
+	if err != nil {
+		t.Fatalf("ApplyRestorePlan: %v", err)
+	}
+
+	rules := target.FirewallRules()
+	byID := make(map[int]bboxclient.FirewallRule)
+	for _, r := range rules {
+		byID[r.ID] = r
+	}
+	for oldID, want := range map[int]string{1: "ssh", 2: "block", 3: "web"} {
+		newID, ok := mapping[bboxclient.SnapshotFirewall][oldID]
+		if !ok || byID[newID].Description != want {
+			t.Errorf("firewall rule %d mapped to %d (%q), want a %q rule", oldID, newID, byID[newID].Description, want)
+		}
+	}
+	if mapping[bboxclient.SnapshotFirewall][1] != 11 || mapping[bboxclient.SnapshotFirewall][2] != 12 {
+		t.Errorf("existing rules not reused: mapping = %v", mapping)
+	}
+	if byID[12].SrcIP != "10.0.0.0/8" {
+		t.Errorf("changed rule not updated: %+v", byID[12])
+	}
+	if len(rules) != 4 {
+		t.Errorf("target has %d firewall rules, want 4", len(rules))
+	}
+
+	nat := target.NatRules()
+	if len(nat) != 1 || mapping[bboxclient.SnapshotNat][7] != nat[0].ID || nat[0].TargetIP != "192.168.1.10" {
+		t.Errorf("NAT rules = %+v, mapping = %v", nat, mapping[bboxclient.SnapshotNat])
+	}
+
+	// Restoring again changes nothing
+	plan, err = client.PlanRestore(snapshot)
+	if err != nil {
+		t.Fatalf("PlanRestore: %v", err)
+	}
+	if !plan.Empty() {
+		t.Errorf("second restore is not empty: %+v", plan.Steps)
+	}
+}
+
+func TestRestorePatchesChangedFields(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "block", Action: bboxclient.ActionDeny, SrcIP: "10.0.0.0/8", Order: 1},
+	})
+	snapshot, err := client.TakeSnapshot(bboxclient.SnapshotFirewall)
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "block", Action: bboxclient.ActionDeny, SrcIP: "10.0.0.1", Order: 1},
+	})
+	plan, err := client.PlanRestore(snapshot)
+	if err != nil {
+		t.Fatalf("PlanRestore: %v", err)
+	}
+	// Edited on the router meanwhile: only the planned changes must be sent
+	server.SetFirewallRules([]bboxclient.FirewallRule{
+		{ID: 1, Description: "block", Action: bboxclient.ActionDeny, SrcIP: "10.0.0.1", DstPorts: "22", Order: 1},
+	})
+	if _, err := client.ApplyRestorePlan(plan); err != nil {
+		t.Fatalf("ApplyRestorePlan: %v", err)
+	}
+
+	if got := server.FirewallRules()[0]; got.SrcIP != "10.0.0.0/8" || got.DstPorts != "22" {
+		t.Errorf("restored rule = %+v", got)
+	}
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tokenRetryDelay is how long the background refresher waits after a failed
+// refresh before trying again
+const tokenRetryDelay = 30 * time.Second
+
+// tokenManager guards the device token required by write calls. Write calls
+// renew the token themselves when it is about to expire; the refresher started
+// by StartTokenRefresher only saves them the round trip.
+type tokenManager struct {
+	mu    sync.Mutex
+	token *DeviceToken
+
+	// refresh is the renewal in flight, shared by the callers that need a
+	// token meanwhile
+	refresh *tokenRefresh
+
+	// stop and done control the background refresher, err records its last
+	// failure until Stop reports it
+	stop context.CancelFunc
+	done chan struct{}
+	err  error
+}
+
+// Bearer returns a copy of the current device token, nil before logging in
+func (bc *BboxClient) Bearer() *DeviceToken {
+	bc.tokens.mu.Lock()
+	defer bc.tokens.mu.Unlock()
+	if bc.tokens.token == nil {
+		return nil
+	}
+	token := *bc.tokens.token
+	return &token
+}
+
+func (bc *BboxClient) setBearer(token *DeviceToken) {
+	if token != nil {
+		copied := *token
+		token = &copied
+	}
+	bc.tokens.mu.Lock()
+	bc.tokens.token = token
+	bc.tokens.mu.Unlock()
+}
+
+// tokenRefresh is one renewal of the device token. done is closed once
+// token or err is set.
+type tokenRefresh struct {
+	done  chan struct{}
+	token *DeviceToken
+	err   error
+}
+
+// validToken returns a device token usable for a write call, obtaining a new
+// one first when the current one expires within tokenExpiryMargin.
+// Concurrent callers share a single refresh, and the lock is not held while
+// it is in flight so Bearer stays available to hooks.
+func (bc *BboxClient) validToken(ctx context.Context) (string, error) {
+	for {
+		bc.tokens.mu.Lock()
+		if bc.tokens.token == nil {
+			bc.tokens.mu.Unlock()
+			return "", errors.New("no bearer token available")
+		}
+		if bc.tokens.token.Valid() {
+			token := bc.tokens.token.Token
+			bc.tokens.mu.Unlock()
+			return token, nil
+		}
+
+		if refresh := bc.tokens.refresh; refresh != nil {
+			bc.tokens.mu.Unlock()
+			select {
+			case <-refresh.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			// A refresh abandoned by its own caller is retried with ours
+			if refresh.err != nil && isContextError(refresh.err) && ctx.Err() == nil {
+				continue
+			}
+			if refresh.err != nil {
+				return "", refresh.err
+			}
+			return refresh.token.Token, nil
+		}
+
+		refresh := &tokenRefresh{done: make(chan struct{})}
+		bc.tokens.refresh = refresh
+		expired := bc.tokens.token
+		bc.tokens.mu.Unlock()
+
+		token, err := bc.Auth().fetchToken(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to refresh device token: %w", err)
+		}
+
+		bc.tokens.mu.Lock()
+		// Keep a token set meanwhile, e.g. by a new login or a logout
+		if err == nil && bc.tokens.token == expired {
+			bc.tokens.token = token
+		}
+		bc.tokens.refresh = nil
+		bc.tokens.mu.Unlock()
+
+		refresh.token, refresh.err = token, err
+		close(refresh.done)
+		if err != nil {
+			return "", err
+		}
+		return token.Token, nil
+	}
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// StartTokenRefresher renews the device token in the background shortly
+// before it expires, until Stop is called
+func (ai *AuthInterface) StartTokenRefresher() error {
+	return ai.StartTokenRefresherContext(context.Background())
+}
+
+// StartTokenRefresherContext is like StartTokenRefresher but also stops when
+// ctx is done
+func (ai *AuthInterface) StartTokenRefresherContext(ctx context.Context) error {
+	tm := &ai.Client.tokens
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.token == nil {
+		return errors.New("can't start before BasicAuth")
+	}
+	if tm.stop != nil {
+		return errors.New("token refresher already running")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	tm.stop, tm.done, tm.err = cancel, make(chan struct{}), nil
+	go ai.refreshLoop(ctx, tm.done)
+	return nil
+}
+
+// Stop ends the background refresher and returns the error of its last
+// refresh if that failed
+func (ai *AuthInterface) Stop() error {
+	tm := &ai.Client.tokens
+	tm.mu.Lock()
+	stop, done := tm.stop, tm.done
+	tm.stop, tm.done = nil, nil
+	tm.mu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	stop()
+	<-done
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	err := tm.err
+	tm.err = nil
+	return err
+}
+
+func (ai *AuthInterface) refreshLoop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	tm := &ai.Client.tokens
+
+	for {
+		wait, err := ai.untilRefresh()
+		if err != nil {
+			tm.setErr(err)
+			return
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		_, err = ai.Client.validToken(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		tm.setErr(err)
+		if err != nil {
+			timer := time.NewTimer(tokenRetryDelay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// untilRefresh returns how long the current token remains valid
+func (ai *AuthInterface) untilRefresh() (time.Duration, error) {
+	token := ai.Client.Bearer()
+	if token == nil {
+		return 0, errors.New("no bearer token available")
+	}
+	expires, err := token.ExpiresAt()
+	if err != nil {
+		return 0, fmt.Errorf("unreadable token expiry %q: %w", token.Expires, err)
+	}
+	wait := time.Until(expires) - tokenExpiryMargin
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, nil
+}
+
+func (tm *tokenManager) setErr(err error) {
+	tm.mu.Lock()
+	tm.err = err
+	tm.mu.Unlock()
+}
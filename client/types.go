@@ -7,6 +7,7 @@ import (
 var (
 	ErrFirewallRuleNotFound = errors.New("firewall rule not found")
 	ErrNatRuleNotFound      = errors.New("NAT rule not found")
+	ErrPinholeNotFound      = errors.New("IPv6 pinhole not found")
 )
 
 // Constants for special values
@@ -30,6 +31,16 @@ const (
 	ActionDeny  Action = "Drop"
 )
 
+// FirewallLevel is the global security level of the firewall
+type FirewallLevel string
+
+const (
+	FirewallLevelLow    FirewallLevel = "low"
+	FirewallLevelMedium FirewallLevel = "medium"
+	FirewallLevelHigh   FirewallLevel = "high"
+	FirewallLevelCustom FirewallLevel = "custom"
+)
+
 // Protocol represents network protocols
 type Protocol string
 
@@ -50,67 +61,113 @@ const (
 
 // FirewallRule represents a single firewall rule configuration
 type FirewallRule struct {
-	ID          int         `json:"id"`
-	Description string      `json:"description"`
-	Enable      EnableState `json:"enable"`
-	Action      Action      `json:"action"`
+	ID          int         `json:"id" yaml:"id"`
+	Description string      `json:"description" yaml:"description"`
+	Enable      EnableState `json:"enable" yaml:"enable"`
+	Action      Action      `json:"action" yaml:"action"`
 
 	// Source configuration
-	SrcIPNot   EnableState `json:"srcipnot"`
-	SrcIP      StringOrInt `json:"srcip"`
-	SrcPortNot EnableState `json:"srcportnot"`
-	SrcPorts   StringOrInt `json:"srcports"`
+	SrcIPNot   EnableState `json:"srcipnot" yaml:"srcipnot"`
+	SrcIP      StringOrInt `json:"srcip" yaml:"srcip"`
+	SrcPortNot EnableState `json:"srcportnot" yaml:"srcportnot"`
+	SrcPorts   StringOrInt `json:"srcports" yaml:"srcports"`
 
 	// Destination configuration
-	DstIPNot   EnableState `json:"dstipnot"`
-	DstIP      StringOrInt `json:"dstip"`
-	DstPortNot EnableState `json:"dstportnot"`
-	DstPorts   StringOrInt `json:"dstports"`
+	DstIPNot   EnableState `json:"dstipnot" yaml:"dstipnot"`
+	DstIP      StringOrInt `json:"dstip" yaml:"dstip"`
+	DstPortNot EnableState `json:"dstportnot" yaml:"dstportnot"`
+	DstPorts   StringOrInt `json:"dstports" yaml:"dstports"`
 
 	// Protocol and ordering
-	Order       int        `json:"order"`
-	Protocols   Protocol   `json:"protocols"`
-	IPProtocol  IPProtocol `json:"ipprotocol"`
-	Utilisation int        `json:"utilisation"`
+	Order       int        `json:"order" yaml:"order"`
+	Protocols   Protocol   `json:"protocols" yaml:"protocols"`
+	IPProtocol  IPProtocol `json:"ipprotocol" yaml:"ipprotocol"`
+	Utilisation int        `json:"utilisation" yaml:"utilisation"`
 }
 
 // Firewall represents a collection of firewall rules
 type Firewall struct {
-	Rules []FirewallRule `json:"rules"`
+	Rules []FirewallRule `json:"rules" yaml:"rules"`
 }
 
 // FirewallResponse wraps the firewall data from API responses
 type FirewallResponse struct {
-	Firewall Firewall `json:"firewall"`
+	Firewall Firewall `json:"firewall" yaml:"firewall"`
 }
 
 // NatResponse wraps the NAT rules data from API responses
 type NatResponse struct {
-	Nat NatRules `json:"nat"`
+	Nat NatRules `json:"nat" yaml:"nat"`
 }
 
 // NatRules represents a collection of NAT rules
 type NatRules struct {
-	Enable EnableState `json:"enable"`
-	Rules  []NatRule   `json:"rules"`
+	Enable EnableState `json:"enable" yaml:"enable"`
+	Rules  []NatRule   `json:"rules" yaml:"rules"`
 }
 
 // NatRule represents a single NAT rule configuration
 type NatRule struct {
-	ID          int         `json:"id"`
-	Enable      EnableState `json:"enable"`
-	Description string      `json:"description"`
+	ID          int         `json:"id" yaml:"id"`
+	Enable      EnableState `json:"enable" yaml:"enable"`
+	Description string      `json:"description" yaml:"description"`
 
 	// Protocol configuration
-	Protocol Protocol `json:"protocol"`
+	Protocol Protocol `json:"protocol" yaml:"protocol"`
 
 	// Source configuration
-	SrcIP    StringOrInt `json:"externalip"`
-	SrcPorts StringOrInt `json:"externalport"`
+	SrcIP    StringOrInt `json:"externalip" yaml:"externalip"`
+	SrcPorts StringOrInt `json:"externalport" yaml:"externalport"`
 
 	// Target configuration
-	TargetIP    StringOrInt `json:"internalip"`
-	TargetPorts StringOrInt `json:"internalport"`
+	TargetIP    StringOrInt `json:"internalip" yaml:"internalip"`
+	TargetPorts StringOrInt `json:"internalport" yaml:"internalport"`
+}
+
+// FirewallSettings holds the global firewall switches that apply on top of
+// the individual rules
+type FirewallSettings struct {
+	Enable        EnableState   `json:"enable" yaml:"enable"`
+	Level         FirewallLevel `json:"level" yaml:"level"`
+	PingResponder EnableState   `json:"pingresponder" yaml:"pingresponder"`
+	GamerMode     EnableState   `json:"gamermode" yaml:"gamermode"`
+}
+
+// FirewallSettingsResponse wraps the global firewall settings from API
+// responses
+type FirewallSettingsResponse struct {
+	Firewall FirewallSettings `json:"firewall" yaml:"firewall"`
+}
+
+// PinholeRule represents an IPv6 pinhole, which lets inbound IPv6 traffic
+// reach a host of the LAN
+type PinholeRule struct {
+	ID          int         `json:"id" yaml:"id"`
+	Enable      EnableState `json:"enable" yaml:"enable"`
+	Description string      `json:"description" yaml:"description"`
+
+	// Source configuration
+	SrcIPNot   EnableState `json:"srcipnot" yaml:"srcipnot"`
+	SrcIP      StringOrInt `json:"srcip" yaml:"srcip"`
+	SrcPortNot EnableState `json:"srcportnot" yaml:"srcportnot"`
+	SrcPorts   StringOrInt `json:"srcports" yaml:"srcports"`
+
+	// Destination configuration
+	DstIP      StringOrInt `json:"dstip" yaml:"dstip"`
+	DstPortNot EnableState `json:"dstportnot" yaml:"dstportnot"`
+	DstPorts   StringOrInt `json:"dstports" yaml:"dstports"`
+
+	Protocols Protocol `json:"protocols" yaml:"protocols"`
+}
+
+// Pinhole represents a collection of IPv6 pinholes
+type Pinhole struct {
+	Rules []PinholeRule `json:"rules" yaml:"rules"`
+}
+
+// PinholeResponse wraps the IPv6 pinhole data from API responses
+type PinholeResponse struct {
+	Pinhole Pinhole `json:"pinhole" yaml:"pinhole"`
 }
 
 // StringOrInt is a custom type to handle fields that can be either string or int wrapped as strings
@@ -25,3 +25,39 @@ func (s *StringOrInt) UnmarshalJSON(data []byte) error {
 func (s StringOrInt) String() string {
 	return string(s)
 }
+
+// UnmarshalJSON accepts the 0/1 the router uses as well as true/false, so
+// rule files can say "enable": true
+func (s *EnableState) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return s.set(v)
+}
+
+// UnmarshalYAML accepts 0/1 as well as true/false
+func (s *EnableState) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	return s.set(v)
+}
+
+func (s *EnableState) set(v interface{}) error {
+	switch val := v.(type) {
+	case bool:
+		*s = Disabled
+		if val {
+			*s = Enabled
+		}
+	case float64:
+		*s = EnableState(val)
+	case int:
+		*s = EnableState(val)
+	default:
+		return fmt.Errorf("cannot unmarshal %v into EnableState", v)
+	}
+	return nil
+}
@@ -0,0 +1,443 @@
+package client
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ValidationError reports an invalid value in one field of a rule
+type ValidationError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s (got %q)", e.Field, e.Reason, e.Value)
+}
+
+// ValidationErrors collects every problem found in a rule
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid rule: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the rule before it is sent to the router and returns
+// ValidationErrors listing every invalid field. Empty addresses and ports
+// mean ANY and are accepted.
+func (r *FirewallRule) Validate() error {
+	var errs ValidationErrors
+
+	if r.Description == "" {
+		errs.add("description", "", "is required")
+	}
+
+	switch r.Action {
+	case ActionAllow, ActionDeny:
+	case "":
+		errs.add("action", "", fmt.Sprintf("is required (%s or %s)", ActionAllow, ActionDeny))
+	default:
+		errs.add("action", string(r.Action), fmt.Sprintf("must be %s or %s", ActionAllow, ActionDeny))
+	}
+
+	if err := validateProtocols(r.Protocols); err != "" {
+		errs.add("protocols", string(r.Protocols), err)
+	}
+
+	ipProtocol := r.IPProtocol
+	switch ipProtocol {
+	case "":
+		ipProtocol = IPProtocolIPv4
+	case IPProtocolIPv4, IPProtocolIPv6, IPProtocolBoth:
+	default:
+		errs.add("ipprotocol", string(r.IPProtocol),
+			fmt.Sprintf("must be %s, %s or %s", IPProtocolIPv4, IPProtocolIPv6, IPProtocolBoth))
+	}
+
+	checkVersion := func(addr AddressMatch) string {
+		if ipProtocol == IPProtocolIPv4 && !addr.Is4() {
+			return "is not an IPv4 address but the rule is IPv4 only"
+		}
+		if ipProtocol == IPProtocolIPv6 && addr.Is4() {
+			return "is not an IPv6 address but the rule is IPv6 only"
+		}
+		return ""
+	}
+	errs.checkAddresses("srcip", r.SrcIP, checkVersion)
+	errs.checkAddresses("dstip", r.DstIP, checkVersion)
+	errs.checkPorts("srcports", r.SrcPorts)
+	errs.checkPorts("dstports", r.DstPorts)
+
+	errs.checkStates(
+		stateField{"enable", r.Enable},
+		stateField{"srcipnot", r.SrcIPNot},
+		stateField{"srcportnot", r.SrcPortNot},
+		stateField{"dstipnot", r.DstIPNot},
+		stateField{"dstportnot", r.DstPortNot},
+	)
+	errs.checkNegation("srcipnot", r.SrcIPNot, r.SrcIP, "source address")
+	errs.checkNegation("dstipnot", r.DstIPNot, r.DstIP, "destination address")
+	errs.checkNegation("srcportnot", r.SrcPortNot, r.SrcPorts, "source port")
+	errs.checkNegation("dstportnot", r.DstPortNot, r.DstPorts, "destination port")
+
+	if r.Order < 0 {
+		errs.add("order", fmt.Sprint(r.Order), "must not be negative")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate checks the pinhole before it is sent to the router. Addresses
+// must be IPv6 and the LAN destination is required.
+func (r *PinholeRule) Validate() error {
+	var errs ValidationErrors
+
+	if r.Description == "" {
+		errs.add("description", "", "is required")
+	}
+
+	if err := validateProtocols(r.Protocols); err != "" {
+		errs.add("protocols", string(r.Protocols), err)
+	}
+
+	if r.DstIP == "" {
+		errs.add("dstip", "", "is required")
+	}
+	checkIPv6 := func(addr AddressMatch) string {
+		if addr.Is4() {
+			return "must be an IPv6 address or prefix"
+		}
+		return ""
+	}
+	errs.checkAddresses("srcip", r.SrcIP, checkIPv6)
+	errs.checkAddresses("dstip", r.DstIP, checkIPv6)
+	errs.checkPorts("srcports", r.SrcPorts)
+	errs.checkPorts("dstports", r.DstPorts)
+
+	errs.checkStates(
+		stateField{"enable", r.Enable},
+		stateField{"srcipnot", r.SrcIPNot},
+		stateField{"srcportnot", r.SrcPortNot},
+		stateField{"dstportnot", r.DstPortNot},
+	)
+	errs.checkNegation("srcipnot", r.SrcIPNot, r.SrcIP, "source address")
+	errs.checkNegation("srcportnot", r.SrcPortNot, r.SrcPorts, "source port")
+	errs.checkNegation("dstportnot", r.DstPortNot, r.DstPorts, "destination port")
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate checks the NAT rule before it is sent to the router. The LAN host
+// and its ports are required; the external address must be IPv4 and empty
+// fields mean ANY.
+func (r *NatRule) Validate() error {
+	var errs ValidationErrors
+
+	if err := validateProtocols(r.Protocol); err != "" {
+		errs.add("protocol", string(r.Protocol), err)
+	}
+
+	errs.checkAddresses("externalip", r.SrcIP, func(addr AddressMatch) string {
+		if !addr.Is4() {
+			return "must be an IPv4 address"
+		}
+		return ""
+	})
+	errs.checkPorts("externalport", r.SrcPorts)
+
+	if r.TargetIP == "" {
+		errs.add("internalip", "", "is required")
+	} else if addr, err := netip.ParseAddr(strings.TrimSpace(r.TargetIP.String())); err != nil || !addr.Is4() {
+		errs.add("internalip", r.TargetIP.String(), "must be a single IPv4 address")
+	}
+	if r.TargetPorts == "" {
+		errs.add("internalport", "", "is required")
+	}
+	errs.checkPorts("internalport", r.TargetPorts)
+
+	errs.checkStates(stateField{"enable", r.Enable})
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Normalize trims the list fields of the rule, e.g. "tcp, udp" becomes
+// "tcp,udp", so that what Validate checks is what gets sent
+func (r *FirewallRule) Normalize() {
+	r.Protocols = Protocol(normalizeList(string(r.Protocols)))
+	r.SrcIP = StringOrInt(normalizeList(string(r.SrcIP)))
+	r.SrcPorts = StringOrInt(normalizeList(string(r.SrcPorts)))
+	r.DstIP = StringOrInt(normalizeList(string(r.DstIP)))
+	r.DstPorts = StringOrInt(normalizeList(string(r.DstPorts)))
+}
+
+// Normalize trims the list fields of the pinhole
+func (r *PinholeRule) Normalize() {
+	r.Protocols = Protocol(normalizeList(string(r.Protocols)))
+	r.SrcIP = StringOrInt(normalizeList(string(r.SrcIP)))
+	r.SrcPorts = StringOrInt(normalizeList(string(r.SrcPorts)))
+	r.DstIP = StringOrInt(normalizeList(string(r.DstIP)))
+	r.DstPorts = StringOrInt(normalizeList(string(r.DstPorts)))
+}
+
+// Normalize trims the list fields and the LAN host of the NAT rule
+func (r *NatRule) Normalize() {
+	r.Protocol = Protocol(normalizeList(string(r.Protocol)))
+	r.SrcIP = StringOrInt(normalizeList(string(r.SrcIP)))
+	r.SrcPorts = StringOrInt(normalizeList(string(r.SrcPorts)))
+	r.TargetIP = StringOrInt(strings.TrimSpace(string(r.TargetIP)))
+	r.TargetPorts = StringOrInt(normalizeList(string(r.TargetPorts)))
+}
+
+// normalizeList trims every entry of a comma separated list. Empty entries
+// are kept so that Validate still reports them.
+func normalizeList(s string) string {
+	parts := strings.Split(strings.TrimSpace(s), ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (e *ValidationErrors) add(field, value, reason string) {
+	*e = append(*e, ValidationError{Field: field, Value: value, Reason: reason})
+}
+
+// checkAddresses validates an address list field. check is called for each
+// address and returns why it is not allowed in the rule, or an empty string.
+func (e *ValidationErrors) checkAddresses(field string, value StringOrInt, check func(AddressMatch) string) {
+	if value == "" {
+		return
+	}
+	addrs, err := ParseAddressList(value.String())
+	if err != nil {
+		e.add(field, value.String(), err.Error())
+		return
+	}
+	for _, addr := range addrs {
+		if reason := check(addr); reason != "" {
+			e.add(field, value.String(), reason)
+			return
+		}
+	}
+}
+
+// checkPorts validates a port list field
+func (e *ValidationErrors) checkPorts(field string, value StringOrInt) {
+	if value == "" {
+		return
+	}
+	if _, err := ParsePortList(value.String()); err != nil {
+		e.add(field, value.String(), err.Error())
+	}
+}
+
+// stateField names an EnableState field for checkStates
+type stateField struct {
+	name  string
+	value EnableState
+}
+
+// checkStates reports the fields that are neither 0 nor 1
+func (e *ValidationErrors) checkStates(fields ...stateField) {
+	for _, f := range fields {
+		if f.value != Enabled && f.value != Disabled {
+			e.add(f.name, fmt.Sprint(f.value), "must be 0 or 1")
+		}
+	}
+}
+
+// checkNegation reports a negation flag set on a field left empty, which
+// would negate ANY
+func (e *ValidationErrors) checkNegation(field string, not EnableState, value StringOrInt, what string) {
+	if not == Enabled && value == "" {
+		e.add(field, "", "cannot negate ANY "+what)
+	}
+}
+
+// validateProtocols returns why a comma-separated protocol list is invalid,
+// or an empty string when it is valid
+func validateProtocols(protocols Protocol) string {
+	if protocols == "" {
+		return ""
+	}
+	seen := make(map[string]bool)
+	for _, p := range strings.Split(string(protocols), ",") {
+		p = strings.TrimSpace(p)
+		switch Protocol(p) {
+		case ProtocolTCP, ProtocolUDP:
+		default:
+			return fmt.Sprintf("must be %s, %s or %s", ProtocolTCP, ProtocolUDP, ProtocolAny)
+		}
+		if seen[p] {
+			return "lists a protocol twice"
+		}
+		seen[p] = true
+	}
+	return ""
+}
+
+// AddressMatch is a parsed rule address: a single IP, a CIDR prefix or an
+// inclusive range written "first-last"
+type AddressMatch struct {
+	First netip.Addr
+	Last  netip.Addr
+}
+
+// ParseAddressMatch parses "192.168.1.10", "192.168.1.0/24",
+// "192.168.1.10-192.168.1.20" and their IPv6 equivalents
+func ParseAddressMatch(s string) (AddressMatch, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return AddressMatch{}, fmt.Errorf("invalid CIDR range")
+		}
+		prefix = prefix.Masked()
+		return AddressMatch{First: prefix.Addr(), Last: lastAddr(prefix)}, nil
+	}
+
+	if first, last, ok := strings.Cut(s, "-"); ok {
+		a, errA := netip.ParseAddr(strings.TrimSpace(first))
+		b, errB := netip.ParseAddr(strings.TrimSpace(last))
+		if errA != nil || errB != nil {
+			return AddressMatch{}, fmt.Errorf("invalid address range")
+		}
+		if a.Is4() != b.Is4() {
+			return AddressMatch{}, fmt.Errorf("address range mixes IPv4 and IPv6")
+		}
+		if b.Less(a) {
+			return AddressMatch{}, fmt.Errorf("address range ends before it starts")
+		}
+		return AddressMatch{First: a, Last: b}, nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return AddressMatch{}, fmt.Errorf("invalid IP address")
+	}
+	return AddressMatch{First: addr, Last: addr}, nil
+}
+
+// ParseAddressList parses a comma-separated list of addresses as accepted by
+// ParseAddressMatch, such as "192.168.1.10,10.0.0.0/8"
+func ParseAddressList(s string) ([]AddressMatch, error) {
+	var matches []AddressMatch
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			return nil, fmt.Errorf("empty entry in address list")
+		}
+		m, err := ParseAddressMatch(part)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// Is4 reports whether the match covers IPv4 addresses
+func (m AddressMatch) Is4() bool {
+	return m.First.Unmap().Is4()
+}
+
+// Contains reports whether addr is within the match
+func (m AddressMatch) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	first, last := m.First.Unmap(), m.Last.Unmap()
+	if addr.Is4() != first.Is4() {
+		return false
+	}
+	return !addr.Less(first) && !last.Less(addr)
+}
+
+// lastAddr returns the highest address of a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	bits := prefix.Bits()
+	for i := range b {
+		hostBits := len(b)*8 - bits - (len(b)-1-i)*8
+		switch {
+		case hostBits >= 8:
+			b[i] = 0xff
+		case hostBits > 0:
+			b[i] |= byte(1<<hostBits - 1)
+		}
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// PortRange is an inclusive range of ports; single ports have First == Last
+type PortRange struct {
+	First int
+	Last  int
+}
+
+// Contains reports whether port is within the range
+func (pr PortRange) Contains(port int) bool {
+	return port >= pr.First && port <= pr.Last
+}
+
+// ParsePortList parses a comma-separated list of ports and ranges such as
+// "80,443,1000-2000"
+func ParsePortList(s string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty entry in port list")
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			// The router also writes ranges as "1000:2000"
+			first, last, isRange = strings.Cut(part, ":")
+		}
+		lo, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+		hi := lo
+		if isRange {
+			if hi, err = parsePort(last); err != nil {
+				return nil, err
+			}
+			if hi < lo {
+				return nil, fmt.Errorf("port range %s ends before it starts", part)
+			}
+		}
+		ranges = append(ranges, PortRange{First: lo, Last: hi})
+	}
+	return ranges, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port %d out of range 1-65535", port)
+	}
+	return port, nil
+}
@@ -0,0 +1,145 @@
+package client_test
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	bboxclient "bbox-cli/client"
+)
+
+func validRule() bboxclient.FirewallRule {
+	return bboxclient.FirewallRule{
+		Description: "web",
+		Action:      bboxclient.ActionAllow,
+		DstIP:       "192.168.1.20",
+		DstPorts:    "80,443,1000-2000",
+		Protocols:   bboxclient.ProtocolAny,
+		IPProtocol:  bboxclient.IPProtocolIPv4,
+		Order:       1,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*bboxclient.FirewallRule)
+		fields []string
+	}{
+		{"valid", func(r *bboxclient.FirewallRule) {}, nil},
+		{"any everywhere", func(r *bboxclient.FirewallRule) { r.DstIP, r.DstPorts, r.Protocols = "", "", "" }, nil},
+		{"cidr", func(r *bboxclient.FirewallRule) { r.SrcIP = "10.0.0.0/8" }, nil},
+		{"range", func(r *bboxclient.FirewallRule) { r.SrcIP = "10.0.0.1-10.0.0.9" }, nil},
+		{"ipv6 rule", func(r *bboxclient.FirewallRule) {
+			r.IPProtocol, r.DstIP = bboxclient.IPProtocolIPv6, "2001:db8::/64"
+		}, nil},
+		{"both versions", func(r *bboxclient.FirewallRule) {
+			r.IPProtocol, r.SrcIP = bboxclient.IPProtocolBoth, "2001:db8::1"
+		}, nil},
+		{"typo in action", func(r *bboxclient.FirewallRule) { r.Action = "Acept" }, []string{"action"}},
+		{"typo in protocol", func(r *bboxclient.FirewallRule) { r.Protocols = "tpc" }, []string{"protocols"}},
+		{"duplicate protocol", func(r *bboxclient.FirewallRule) { r.Protocols = "tcp, tcp" }, []string{"protocols"}},
+		{"address list", func(r *bboxclient.FirewallRule) { r.SrcIP = "10.0.0.1, 192.168.1.0/24" }, nil},
+		{"bad address in list", func(r *bboxclient.FirewallRule) { r.SrcIP = "10.0.0.1,10.0.0.300" }, []string{"srcip"}},
+		{"empty address entry", func(r *bboxclient.FirewallRule) { r.SrcIP = "10.0.0.1,,10.0.0.2" }, []string{"srcip"}},
+		{"ipv6 in ipv4 list", func(r *bboxclient.FirewallRule) { r.DstIP = "192.168.1.20,2001:db8::1" }, []string{"dstip"}},
+		{"bad octet", func(r *bboxclient.FirewallRule) { r.DstIP = "192.168.1.300" }, []string{"dstip"}},
+		{"bad prefix", func(r *bboxclient.FirewallRule) { r.SrcIP = "10.0.0.0/33" }, []string{"srcip"}},
+		{"reversed range", func(r *bboxclient.FirewallRule) { r.SrcIP = "10.0.0.9-10.0.0.1" }, []string{"srcip"}},
+		{"ipv6 in ipv4 rule", func(r *bboxclient.FirewallRule) { r.DstIP = "2001:db8::1" }, []string{"dstip"}},
+		{"ipv4 in ipv6 rule", func(r *bboxclient.FirewallRule) { r.IPProtocol = bboxclient.IPProtocolIPv6 }, []string{"dstip"}},
+		{"port out of range", func(r *bboxclient.FirewallRule) { r.SrcPorts = "70000" }, []string{"srcports"}},
+		{"reversed ports", func(r *bboxclient.FirewallRule) { r.DstPorts = "2000-1000" }, []string{"dstports"}},
+		{"empty port entry", func(r *bboxclient.FirewallRule) { r.DstPorts = "80,,443" }, []string{"dstports"}},
+		{"negated any", func(r *bboxclient.FirewallRule) { r.SrcIPNot = bboxclient.Enabled }, []string{"srcipnot"}},
+		{"several", func(r *bboxclient.FirewallRule) {
+			r.Description, r.Action, r.IPProtocol = "", "", "IPv5"
+		}, []string{"description", "action", "ipprotocol"}},
+	}
+
+	for _, tt := range tests {
+		rule := validRule()
+		tt.modify(&rule)
+		err := rule.Validate()
+
+		var errs bboxclient.ValidationErrors
+		if err != nil && !errors.As(err, &errs) {
+			t.Errorf("%s: err = %v, want ValidationErrors", tt.name, err)
+			continue
+		}
+		if len(errs) != len(tt.fields) {
+			t.Errorf("%s: got errors %v, want fields %v", tt.name, errs, tt.fields)
+			continue
+		}
+		for i, field := range tt.fields {
+			if errs[i].Field != field {
+				t.Errorf("%s: error %d on %q, want %q", tt.name, i, errs[i].Field, field)
+			}
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	rule := validRule()
+	rule.Protocols = " tcp, udp "
+	rule.SrcIP = "10.0.0.1 , 10.0.1.0/24"
+	rule.DstPorts = "80, 443,"
+	rule.Normalize()
+
+	if rule.Protocols != "tcp,udp" || rule.SrcIP != "10.0.0.1,10.0.1.0/24" || rule.DstPorts != "80,443," {
+		t.Errorf("Normalize = %q %q %q", rule.Protocols, rule.SrcIP, rule.DstPorts)
+	}
+	// The empty port entry is still reported
+	if err := rule.Validate(); err == nil {
+		t.Error("Validate accepted an empty port entry")
+	}
+
+	nat := bboxclient.NatRule{Protocol: "tcp ,udp", TargetIP: " 192.168.1.20 ", TargetPorts: "22, 80"}
+	nat.Normalize()
+	if nat.Protocol != "tcp,udp" || nat.TargetIP != "192.168.1.20" || nat.TargetPorts != "22,80" {
+		t.Errorf("Normalize = %+v", nat)
+	}
+}
+
+func TestParsePortList(t *testing.T) {
+	ranges, err := bboxclient.ParsePortList("80, 443,1000-2000,3000:3010")
+	if err != nil {
+		t.Fatalf("ParsePortList: %v", err)
+	}
+	want := []bboxclient.PortRange{{80, 80}, {443, 443}, {1000, 2000}, {3000, 3010}}
+	if len(ranges) != len(want) {
+		t.Fatalf("ranges = %v, want %v", ranges, want)
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("ranges[%d] = %v, want %v", i, ranges[i], want[i])
+		}
+	}
+}
+
+func TestAddressMatchContains(t *testing.T) {
+	tests := []struct {
+		match string
+		addr  string
+		want  bool
+	}{
+		{"192.168.1.0/24", "192.168.1.255", true},
+		{"192.168.1.0/24", "192.168.2.0", false},
+		{"192.168.1.77/24", "192.168.1.1", true},
+		{"10.0.0.1-10.0.0.9", "10.0.0.5", true},
+		{"10.0.0.1-10.0.0.9", "10.0.0.10", false},
+		{"2001:db8::/32", "2001:db8:ffff::1", true},
+		{"2001:db8::/32", "192.168.1.1", false},
+		{"10.0.0.1", "10.0.0.1", true},
+	}
+	for _, tt := range tests {
+		m, err := bboxclient.ParseAddressMatch(tt.match)
+		if err != nil {
+			t.Errorf("ParseAddressMatch(%q): %v", tt.match, err)
+			continue
+		}
+		if got := m.Contains(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("%s contains %s = %t, want %t", tt.match, tt.addr, got, tt.want)
+		}
+	}
+}